@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/debugger"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+const debugPrompt = "(lox) "
+
+const debugHelp = `break <line> [if <cond>]  pause before line, when cond is true
+delete                    remove every breakpoint
+step                      run to the next line, entering calls
+next                      run to the next line in this function
+finish                    run until this function returns
+continue                  run to the next breakpoint
+print <expr>              evaluate expr in the current scope
+locals                    list the variables in the current scope
+backtrace                 list the calls on the stack
+watch <var>               pause when var changes
+quit                      stop the script and leave
+An empty line repeats the previous command.
+`
+
+var debugCommands = []string{"break", "delete", "step", "next", "finish", "continue", "print", "locals", "backtrace", "watch", "quit", "help"}
+
+// debugAliases maps gdb's short command names to the full ones.
+var debugAliases = map[string]string{
+	"b": "break", "s": "step", "n": "next", "c": "continue", "p": "print",
+	"bt": "backtrace", "where": "backtrace", "q": "quit", "h": "help",
+}
+
+// debugSession runs source under a terminal debugger, paused before its first
+// statement, and returns the script's error once it exits.
+func debugSession(source string, opts []lox.Option) error {
+	// standard input carries the debugger's commands
+	opts = append(slices.Clone(opts), lox.WithStdin(strings.NewReader("")))
+	dbg := debugger.New(opts...)
+	if err := dbg.Load(source); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	lines := strings.Split(source, "\n")
+	readLine, addHistory := lineInput(func(prefix string) []string {
+		return completions(prefix, debugCommands)
+	})
+
+	// an interrupt pauses the running script instead of ending the session
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	dbg.Start(context.Background(), true)
+	last := ""
+	for {
+		var ev debugger.Event
+		select {
+		case ev = <-dbg.Events():
+		case <-interrupts:
+			dbg.Pause()
+			continue
+		}
+		if ev.Reason == debugger.ReasonExited {
+			if ev.Err != nil {
+				fmt.Println("[script exited with an error]")
+			} else {
+				fmt.Println("[script exited]")
+			}
+			return ev.Err
+		}
+		switch ev.Reason {
+		case debugger.ReasonBreakpoint:
+			fmt.Printf("Breakpoint %v\n", ev.Breakpoint)
+		case debugger.ReasonWatch:
+			fmt.Printf("Watch %v: %v -> %v\n", ev.Watch, orUndefined(ev.Old), ev.New)
+		}
+		printSourceLine(lines, ev.Line)
+
+		for resumed := false; !resumed; {
+			line, err := readLine(debugPrompt)
+			if errors.Is(err, errInterrupted) {
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				dbg.Terminate()
+				return err
+			}
+			if strings.TrimSpace(line) == "" {
+				line = last
+			} else if addHistory != nil {
+				addHistory(line)
+			}
+			last = line
+			if name, _ := debugParse(line); name == "quit" {
+				// the paused script ends with the process
+				return nil
+			}
+			resumed = debugCommand(dbg, line)
+		}
+	}
+}
+
+// debugCommand runs one command line and reports whether it resumed the
+// script.
+func debugCommand(dbg *debugger.Debugger, line string) bool {
+	name, arg := debugParse(line)
+	var err error
+	switch name {
+	case "":
+	case "break":
+		lineArg, cond, _ := strings.Cut(arg, " if ")
+		n, convErr := strconv.Atoi(strings.TrimSpace(lineArg))
+		if convErr != nil {
+			fmt.Println("usage: break <line> [if <cond>]")
+			return false
+		}
+		var bp *debugger.Breakpoint
+		if bp, err = dbg.AddBreakpoint(n, strings.TrimSpace(cond)); err == nil {
+			fmt.Printf("Breakpoint %v at line %v\n", bp.ID, bp.Line)
+		}
+	case "delete":
+		dbg.ClearBreakpoints()
+	case "step":
+		return dbg.StepIn() == nil
+	case "next":
+		return dbg.StepOver() == nil
+	case "finish":
+		return dbg.StepOut() == nil
+	case "continue":
+		return dbg.Continue() == nil
+	case "print":
+		var value lox.Value
+		if value, err = dbg.Evaluate(arg, 0); err == nil {
+			fmt.Println(lox.Stringify(value))
+		}
+	case "locals":
+		err = printLocals(dbg)
+	case "backtrace":
+		var frames []debugger.Frame
+		if frames, err = dbg.Frames(); err == nil {
+			for i, f := range frames {
+				fmt.Printf("#%v  %v at line %v\n", i, f.Name, f.Line)
+			}
+		}
+	case "watch":
+		if arg == "" {
+			fmt.Println("usage: watch <var>")
+			return false
+		}
+		dbg.Watch(arg)
+		fmt.Printf("Watching %v\n", arg)
+	case "help":
+		fmt.Print(debugHelp)
+	default:
+		fmt.Printf("unknown command %q, try help\n", name)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	return false
+}
+
+// debugParse splits a command line into the command's full name and its
+// argument.
+func debugParse(line string) (name, arg string) {
+	name, arg, _ = strings.Cut(strings.TrimSpace(line), " ")
+	if full, ok := debugAliases[name]; ok {
+		name = full
+	}
+	return name, strings.TrimSpace(arg)
+}
+
+// printLocals lists the variables of the innermost scope. At the top level
+// that is the globals, less the native functions.
+func printLocals(dbg *debugger.Debugger) error {
+	frames, err := dbg.Frames()
+	if err != nil {
+		return err
+	}
+	env := frames[0].Env
+	if env == nil {
+		return nil
+	}
+	for _, name := range env.Names() {
+		value, _ := env.Lookup(name)
+		if _, native := value.(*lox.NativeFunction); native {
+			continue
+		}
+		fmt.Printf("%v = %v\n", name, lox.Stringify(value))
+	}
+	return nil
+}
+
+func printSourceLine(lines []string, line int) {
+	text := ""
+	if line >= 1 && line <= len(lines) {
+		text = lines[line-1]
+	}
+	fmt.Printf("%v\t%v\n", line, text)
+}
+
+func orUndefined(s string) string {
+	if s == "" {
+		return "<undefined>"
+	}
+	return s
+}
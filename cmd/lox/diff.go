@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const diffContext = 3
+
+// writeDiff writes a unified diff turning a into b, computed from the longest
+// common subsequence of their lines.
+func writeDiff(w io.Writer, name, a, b string) {
+	x, y := splitLines(a), splitLines(b)
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type edit struct {
+		op   byte // ' ', '-' or '+'
+		line string
+		i, j int // lines of x and y before this edit
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i], i, j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', x[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', y[j], i, j})
+			j++
+		}
+	}
+
+	fmt.Fprintf(w, "--- %v.orig\n+++ %v\n", name, name)
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// grow the hunk until diffContext*2 unchanged lines separate changes
+		from := max(start-diffContext, 0)
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= diffContext*2 {
+				break
+			}
+		}
+		to := min(end+diffContext, len(edits))
+		var lenX, lenY int
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				lenX++
+			}
+			if e.op != '-' {
+				lenY++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%v,%v +%v,%v @@\n", edits[from].i+1, lenX, edits[from].j+1, lenY)
+		for _, e := range edits[from:to] {
+			fmt.Fprintf(w, "%c%v\n", e.op, e.line)
+		}
+		start = to
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
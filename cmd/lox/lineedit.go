@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal in raw mode, with cursor movement,
+// history and tab completion.
+type lineEditor struct {
+	fd       uintptr
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete func(prefix string) []string
+}
+
+func newLineEditor(in *os.File, out io.Writer, complete func(prefix string) []string) *lineEditor {
+	return &lineEditor{fd: in.Fd(), in: bufio.NewReader(in), out: out, complete: complete}
+}
+
+// readLine reads one line after showing prompt. It returns io.EOF when the user
+// presses Ctrl-D on an empty line and errInterrupted on Ctrl-C.
+func (ed *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(ed.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	var line []rune
+	pos := 0
+	// hist is the history entry being shown; len(ed.history) is the new line
+	hist := len(ed.history)
+	saved := ""
+	redraw := func() {
+		fmt.Fprintf(ed.out, "\r\x1b[K%v%v", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(ed.out, "\x1b[%vD", back)
+		}
+	}
+	show := func(s string) {
+		line = []rune(s)
+		pos = len(line)
+		redraw()
+	}
+	redraw()
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(ed.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case '\t':
+			line, pos = ed.completeWord(line, pos, prompt)
+		case 27: // escape sequence
+			switch ed.readEscape() {
+			case "[A": // up
+				if hist > 0 {
+					if hist == len(ed.history) {
+						saved = string(line)
+					}
+					hist--
+					show(ed.history[hist])
+				}
+				continue
+			case "[B": // down
+				if hist < len(ed.history) {
+					hist++
+					if hist == len(ed.history) {
+						show(saved)
+					} else {
+						show(ed.history[hist])
+					}
+				}
+				continue
+			case "[C":
+				if pos < len(line) {
+					pos++
+				}
+			case "[D":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(line)
+			case "[3~": // delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC, such as "[A" for
+// the up arrow.
+func (ed *lineEditor) readEscape() string {
+	var sb strings.Builder
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return sb.String()
+		}
+		sb.WriteRune(r)
+		// sequences end with a letter or '~', after the introducer
+		if sb.Len() > 1 && (unicode.IsLetter(r) || r == '~') {
+			return sb.String()
+		}
+		if sb.Len() == 1 && r != '[' && r != 'O' {
+			return sb.String()
+		}
+	}
+}
+
+// completeWord completes the identifier before the cursor. With several
+// candidates it extends the word to their common prefix, or lists them when
+// the word cannot be extended.
+func (ed *lineEditor) completeWord(line []rune, pos int, prompt string) ([]rune, int) {
+	start := pos
+	for start > 0 && (unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1]) || line[start-1] == '_') {
+		start--
+	}
+	prefix := string(line[start:pos])
+	if prefix == "" || ed.complete == nil {
+		return line, pos
+	}
+	candidates := ed.complete(prefix)
+	if len(candidates) == 0 {
+		return line, pos
+	}
+	common := commonPrefix(candidates)
+	if len(candidates) > 1 && common == prefix {
+		fmt.Fprintf(ed.out, "\r\n%v\r\n", strings.Join(candidates, "  "))
+		return line, pos
+	}
+	if len(candidates) == 1 {
+		common += " "
+	}
+	rest := []rune(strings.TrimPrefix(common, prefix))
+	line = append(line[:pos], append(rest, line[pos:]...)...)
+	return line, pos + len(rest)
+}
+
+// commonPrefix returns the longest prefix of whole runes shared by words.
+func commonPrefix(words []string) string {
+	common := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, common) {
+			_, size := utf8.DecodeLastRuneInString(common)
+			common = common[:len(common)-size]
+		}
+	}
+	return common
+}
+
+// addHistory records line as the most recent history entry.
+func (ed *lineEditor) addHistory(line string) {
+	if n := len(ed.history); n > 0 && ed.history[n-1] == line {
+		return
+	}
+	ed.history = append(ed.history, line)
+}
+
+// completions returns the words starting with prefix, sorted and without
+// duplicates.
+func completions(prefix string, words ...[]string) []string {
+	seen := make(map[string]bool)
+	var matches []string
+	for _, list := range words {
+		for _, w := range list {
+			if strings.HasPrefix(w, prefix) && !seen[w] {
+				seen[w] = true
+				matches = append(matches, w)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"print"}, "print"},
+		{[]string{"print", "prime"}, "pri"},
+		{[]string{"var", "fun"}, ""},
+		// é and è share their first byte, which must not be kept alone
+		{[]string{"café", "cafè"}, "caf"},
+		{[]string{"πx", "πy"}, "π"},
+	}
+	for _, tt := range tests {
+		got := commonPrefix(tt.words)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
@@ -1,156 +1,487 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/codecrafters-io/interpreter-starter-go/internal/coverage"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/dap"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/lsp"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/profile"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
 	"github.com/sanity-io/litter"
 )
 
-var (
-	verbose bool
-)
+// config holds the command line flags shared by the subcommands.
+type config struct {
+	verbose      bool
+	trace        string
+	traceFormat  string
+	maxDepth     int
+	timeout      time.Duration
+	maxSteps     int
+	maxStringLen int
+	restorePath  string
+	snapshotPath string
+	format       string
+	profilePath  string
+	coveragePath string
+}
 
 func main() {
+	var cfg config
 	tokenizeCmd := flag.NewFlagSet("tokenize", flag.ExitOnError)
 	parseCmd := flag.NewFlagSet("parse", flag.ExitOnError)
 	evaluateCmd := flag.NewFlagSet("evaluate", flag.ExitOnError)
 	runCmd := flag.NewFlagSet("run", flag.ExitOnError)
-	for _, fs := range []*flag.FlagSet{tokenizeCmd, parseCmd, evaluateCmd, runCmd} {
-		fs.BoolVar(&verbose, "verbose", false, "enable verbose mode")
+	replCmd := flag.NewFlagSet("repl", flag.ExitOnError)
+	fmtCmd := flag.NewFlagSet("fmt", flag.ExitOnError)
+	lspCmd := flag.NewFlagSet("lsp", flag.ExitOnError)
+	dapCmd := flag.NewFlagSet("dap", flag.ExitOnError)
+	debugCmd := flag.NewFlagSet("debug", flag.ExitOnError)
+	for _, fs := range []*flag.FlagSet{tokenizeCmd, parseCmd, evaluateCmd, runCmd, replCmd} {
+		fs.BoolVar(&cfg.verbose, "verbose", false, "trace every category, same as --trace=all")
+		fs.StringVar(&cfg.trace, "trace", "", "comma separated trace categories: lexer, parser, eval, env or all")
+		fs.StringVar(&cfg.traceFormat, "trace-format", "text", "trace output format: text or json")
+	}
+	for _, fs := range []*flag.FlagSet{parseCmd, evaluateCmd, runCmd, replCmd, dapCmd, debugCmd} {
+		fs.IntVar(&cfg.maxDepth, "max-depth", lox.DefaultMaxDepth, "maximum nesting and call depth")
+	}
+	for _, fs := range []*flag.FlagSet{evaluateCmd, runCmd, replCmd} {
+		fs.DurationVar(&cfg.timeout, "timeout", 0, "abort evaluation after this long (0 means no limit)")
+		fs.IntVar(&cfg.maxSteps, "max-steps", 0, "abort evaluation after this many steps (0 means no limit)")
+		fs.IntVar(&cfg.maxStringLen, "max-string-len", 0, "maximum length of a string value in bytes (0 means no limit)")
 	}
-	if len(os.Args) < 3 {
+	tokenizeCmd.StringVar(&cfg.format, "format", "text", "output format: text, json or tsv")
+	parseCmd.StringVar(&cfg.format, "format", "text", "output format: text, json or dot")
+	runCmd.StringVar(&cfg.format, "format", "text", "input format: text for source or json for a syntax tree written by parse")
+	runCmd.StringVar(&cfg.restorePath, "restore", "", "restore globals from a snapshot file before running")
+	runCmd.StringVar(&cfg.profilePath, "profile", "", "write a pprof profile of the run to this file and a summary to standard error")
+	runCmd.StringVar(&cfg.coveragePath, "coverage", "", "write the run's line and branch coverage to this file in LCOV format and a summary to standard error")
+	runCmd.StringVar(&cfg.snapshotPath, "snapshot", "", "save globals to a snapshot file after running")
+	write := fmtCmd.Bool("w", false, "write the result to the source file instead of standard output")
+	diff := fmtCmd.Bool("d", false, "print a diff of the changes instead of the formatted source")
+	// editors pass --stdio to select the only transport there is
+	lspCmd.Bool("stdio", true, "serve over standard input and output")
+	if len(os.Args) < 2 {
 		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh COMMAND <filename>")
 		os.Exit(1)
 	}
 
-	logger := log.Default()
-
 	command := os.Args[1]
-	eof := Token{Type: TokenEOF}
 
 	switch command {
 	case "tokenize":
 		tokenizeCmd.Parse(os.Args[2:])
-		if !verbose {
-			logger.SetOutput(io.Discard)
-		}
 		if len(tokenizeCmd.Args()) != 1 {
-			logger.Fatal("Usage: ./your_program.sh tokenize <filename>")
-		}
-		source := fileContents(tokenizeCmd.Args()[0])
-		var foundIllegalToken bool
-		if len(source) > 0 {
-			lexer := NewLexer(string(source))
-			for tok := lexer.Next(); tok.Type != TokenEOF; tok = lexer.Next() {
-				if tok.Type == TokenIllegal {
-					foundIllegalToken = true
-				} else if tok.Type != TokenComment {
-					fmt.Printf("%+v\n", tok)
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh tokenize <filename>")
+			os.Exit(1)
+		}
+		tracer := newTracer(cfg)
+		source, err := openSource(tokenizeCmd.Args()[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer source.Close()
+		lexer := lox.NewLexerFromReader(source)
+		lexer.SetTracer(tracer)
+		if cfg.format != "text" {
+			if err := writeTokens(os.Stdout, lexer, cfg.format); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if errs := lexer.Errors(); len(errs) > 0 {
+				for _, err := range errs {
+					fmt.Fprintln(os.Stderr, err)
 				}
+				os.Exit(65)
+			}
+			return
+		}
+		tok := lexer.Next()
+		for ; tok.Type != lox.TokenEOF; tok = lexer.Next() {
+			if tok.Type != lox.TokenIllegal && tok.Type != lox.TokenComment {
+				fmt.Printf("%+v\n", tok)
 			}
 		}
-		fmt.Printf("%+v\n", eof)
-		if foundIllegalToken {
+		fmt.Printf("%+v\n", tok)
+		if errs := lexer.Errors(); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
 			os.Exit(65)
 		}
 	case "parse":
 		parseCmd.Parse(os.Args[2:])
-		if !verbose {
-			logger.SetOutput(io.Discard)
-		}
 		if len(parseCmd.Args()) != 1 {
-			logger.Fatal("Usage: ./your_program.sh parse <filename>")
-		}
-		source := fileContents(parseCmd.Args()[0])
-		tokens := make([]Token, 0)
-		if len(source) > 0 {
-			lexer := NewLexer(string(source))
-			for tok := lexer.Next(); tok.Type != TokenEOF; tok = lexer.Next() {
-				if tok.Type == TokenIllegal {
-					os.Exit(65)
-				}
-				tokens = append(tokens, tok)
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh parse <filename>")
+			os.Exit(1)
+		}
+		tracer := newTracer(cfg)
+		source, err := openSource(parseCmd.Args()[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer source.Close()
+		interp := lox.New(lox.WithTracer(tracer), lox.WithMaxDepth(cfg.maxDepth))
+		block, err := interp.ParseReader(source)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitOnError(err)
+		}
+		switch cfg.format {
+		case "text":
+			fmt.Printf("%v\n", block)
+		case "json":
+			out, err := json.MarshalIndent(lox.NewProgram(block), "", "  ")
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Println(string(out))
+		case "dot":
+			if err := lox.NewProgram(block).WriteDot(os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
 			}
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", cfg.format)
+			os.Exit(1)
+		}
+		if tracer.Enabled(lox.TraceParser) {
+			tracer.Log(lox.TraceParser, "ast", "dump", litter.Sdump(block))
 		}
-
-		tokens = append(tokens, eof)
-		parser := NewParser(tokens, logger)
-		block := parser.Parse(tokens)
-		fmt.Printf("%v\n", block)
-		logger.Println(litter.Sdump(block))
 	case "evaluate":
 		evaluateCmd.Parse(os.Args[2:])
-		if !verbose {
-			logger.SetOutput(io.Discard)
-		}
 		if len(evaluateCmd.Args()) != 1 {
-			logger.Fatal("Usage: ./your_program.sh evaluate <filename>")
-		}
-		source := fileContents(evaluateCmd.Args()[0])
-		tokens := make([]Token, 0)
-		if len(source) > 0 {
-			lexer := NewLexer(string(source))
-			for tok := lexer.Next(); tok.Type != TokenEOF; tok = lexer.Next() {
-				if tok.Type == TokenIllegal {
-					os.Exit(65)
-				}
-				tokens = append(tokens, tok)
-			}
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh evaluate <filename>")
+			os.Exit(1)
 		}
-
-		tokens = append(tokens, eof)
-		parser := NewParser(tokens, logger)
-		expr := parseExpression(parser, Lowest)
-		evaluator := Evaluator{log: logger, env: NewEnvironment(logger, nil)}
-		result, err := evaluator.EvalExpr(expr)
+		tracer := newTracer(cfg)
+		source, err := fileContents(evaluateCmd.Args()[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		interp := newInterpreter(tracer, cfg)
+		ctx, cancel := evalContext(cfg)
+		defer cancel()
+		result, err := interp.EvalContext(ctx, source)
 		if err != nil {
-			os.Exit(70)
+			exitOnError(err)
 		}
-		fmt.Println(result)
+		fmt.Println(lox.Stringify(result))
 	case "run":
 		runCmd.Parse(os.Args[2:])
-		if !verbose {
-			logger.SetOutput(io.Discard)
-		}
 		if len(runCmd.Args()) != 1 {
-			logger.Fatal("Usage: ./your_program.sh run <filename>")
-		}
-		source := fileContents(runCmd.Args()[0])
-		tokens := make([]Token, 0)
-		if len(source) > 0 {
-			lexer := NewLexer(string(source))
-			for tok := lexer.Next(); tok.Type != TokenEOF; tok = lexer.Next() {
-				if tok.Type == TokenIllegal {
-					os.Exit(65)
-				}
-				tokens = append(tokens, tok)
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh run <filename>")
+			os.Exit(1)
+		}
+		tracer := newTracer(cfg)
+		source, err := openSource(runCmd.Args()[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer source.Close()
+		var prog *lox.Program
+		switch cfg.format {
+		case "text":
+			prog, err = lox.CompileReader(source, lox.WithTracer(tracer), lox.WithMaxDepth(cfg.maxDepth))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				exitOnError(err)
+			}
+		case "json":
+			prog = &lox.Program{}
+			if err := json.NewDecoder(source).Decode(prog); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(65)
 			}
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format %q\n", cfg.format)
+			os.Exit(1)
 		}
-
-		logger.Printf("--- END of lexing ---")
-		tokens = append(tokens, eof)
-		parser := NewParser(tokens, logger)
-		block := parser.Parse(tokens)
-		logger.Printf("--- END of parsing ---")
-		evaluator := Evaluator{env: NewEnvironment(logger, nil), log: logger}
-		err := evaluator.Eval(block)
+		var prof *profile.Profiler
+		var cov *coverage.Coverage
+		var opts []lox.Option
+		if cfg.profilePath != "" {
+			prof = profile.New(runCmd.Args()[0])
+			opts = append(opts, lox.WithObserver(prof))
+		}
+		if cfg.coveragePath != "" {
+			cov = coverage.New(runCmd.Args()[0], prog.Block())
+			opts = append(opts, lox.WithObserver(cov))
+		}
+		interp := newInterpreter(tracer, cfg, opts...)
+		if cfg.restorePath != "" {
+			if err := restoreSnapshot(interp, cfg.restorePath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		ctx, cancel := evalContext(cfg)
+		defer cancel()
+		err = interp.Exec(ctx, prog)
+		if prof != nil {
+			if err := writeProfile(prof, cfg.profilePath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if cov != nil {
+			if err := writeCoverage(cov, cfg.coveragePath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if err != nil {
+			exitOnError(err)
+		}
+		if cfg.snapshotPath != "" {
+			if err := saveSnapshot(interp, cfg.snapshotPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	case "fmt":
+		fmtCmd.Parse(os.Args[2:])
+		names := fmtCmd.Args()
+		if len(names) == 0 {
+			names = []string{"-"}
+		}
+		status := 0
+		for _, name := range names {
+			if err := formatFile(name, *write, *diff); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 65
+			}
+		}
+		os.Exit(status)
+	case "repl":
+		replCmd.Parse(os.Args[2:])
+		if len(replCmd.Args()) != 0 {
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh repl")
+			os.Exit(1)
+		}
+		interp := newInterpreter(newTracer(cfg), cfg)
+		if err := repl(interp, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "lsp":
+		lspCmd.Parse(os.Args[2:])
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "dap":
+		dapCmd.Parse(os.Args[2:])
+		if err := dap.NewServer(os.Stdin, os.Stdout, lox.WithMaxDepth(cfg.maxDepth)).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "debug":
+		debugCmd.Parse(os.Args[2:])
+		if len(debugCmd.Args()) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh debug <filename>")
+			os.Exit(1)
+		}
+		// standard input carries the debugger's commands, so the script
+		// must come from a file
+		source, err := os.ReadFile(debugCmd.Args()[0])
 		if err != nil {
-			os.Exit(70)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := debugSession(string(source), []lox.Option{lox.WithMaxDepth(cfg.maxDepth), lox.WithStderr(os.Stderr)}); err != nil {
+			exitOnError(err)
 		}
 	default:
 		fmt.Printf("invalid command: %v\n", command)
 	}
 }
 
-func fileContents(filename string) string {
-	b, err := os.ReadFile(filename)
+// newTracer returns the tracer selected by the --trace, --trace-format and
+// --verbose flags, or nil when tracing is off. It exits on invalid flags.
+func newTracer(cfg config) *lox.Tracer {
+	categories, err := lox.ParseTraceCategories(cfg.trace)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.verbose {
+		categories = lox.TraceAll
+	}
+	if categories == 0 {
+		return nil
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	switch cfg.traceFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown trace format %q\n", cfg.traceFormat)
+		os.Exit(1)
+	}
+	return lox.NewTracer(slog.New(handler), categories)
+}
+
+func newInterpreter(tracer *lox.Tracer, cfg config, opts ...lox.Option) *lox.Interpreter {
+	return lox.New(append([]lox.Option{
+		lox.WithTracer(tracer),
+		lox.WithMaxDepth(cfg.maxDepth),
+		lox.WithMaxSteps(cfg.maxSteps),
+		lox.WithMaxStringLen(cfg.maxStringLen),
+		lox.WithStderr(os.Stderr),
+	}, opts...)...)
+}
+
+// writeProfile saves the profile of a finished run to path and summarises it
+// on standard error.
+func writeProfile(prof *profile.Profiler, path string) error {
+	prof.Stop()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := prof.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	return prof.WriteSummary(os.Stderr, 10)
+}
+
+// writeCoverage saves the coverage of a finished run to path and summarises it
+// on standard error.
+func writeCoverage(cov *coverage.Coverage, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cov.WriteLCOV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	return cov.WriteSummary(os.Stderr)
+}
+
+func evalContext(cfg config) (context.Context, context.CancelFunc) {
+	if cfg.timeout > 0 {
+		return context.WithTimeout(context.Background(), cfg.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// exitOnError exits with 65 for malformed source, 75 when a resource limit
+// stopped the script and 70 for any other runtime error.
+func exitOnError(err error) {
+	var (
+		scanErr  lox.ScanError
+		parseErr lox.ParseError
+		limitErr lox.LimitError
+	)
+	switch {
+	case errors.As(err, &scanErr), errors.As(err, &parseErr):
+		os.Exit(65)
+	case errors.As(err, &limitErr):
+		os.Exit(75)
+	}
+	os.Exit(70)
+}
 
+func restoreSnapshot(interp *lox.Interpreter, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return interp.Restore(f)
+}
+
+func saveSnapshot(interp *lox.Interpreter, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	skipped, err := interp.Snapshot(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "snapshot: skipped functions %v\n", strings.Join(skipped, ", "))
+	}
+	return f.Close()
+}
+
+// formatFile formats the named source file, writing it back, printing a diff
+// or printing the result depending on the flags.
+func formatFile(name string, write, diff bool) error {
+	source, err := fileContents(name)
+	if err != nil {
+		return err
+	}
+	formatted, err := lox.Format(source)
+	if err != nil {
+		if name != "-" {
+			err = fmt.Errorf("%v: %w", name, err)
+		}
+		return err
+	}
+	switch {
+	case diff:
+		if formatted != source {
+			writeDiff(os.Stdout, name, source, formatted)
+		}
+	case write && name != "-":
+		if formatted != source {
+			return os.WriteFile(name, []byte(formatted), 0o644)
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return nil
+}
+
+// openSource opens the named source file, or standard input if name is "-".
+func openSource(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+func fileContents(name string) (string, error) {
+	f, err := openSource(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
 	}
-	return string(b)
+	return string(b), nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the command itself when the tests re-execute their binary
+// through runLox, so that its exit codes can be checked.
+func TestMain(m *testing.M) {
+	if os.Getenv("LOX_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runLox runs the command with args and source in a temporary file named by the
+// last argument, returning its standard output, standard error and exit code.
+func runLox(t *testing.T, source string, args ...string) (string, string, int) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], append(args, path)...)
+	cmd.Env = append(os.Environ(), "LOX_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("run %v: %v", args, err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		args       []string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{"caught throw", `try { throw 1; } catch (e) { print e; }`, nil, "1\n", "", 0},
+		{"uncaught throw", "print 1;\nthrow \"x\";\nprint 2;", nil, "1\n", "uncaught exception: x\n", 70},
+		{"uncaught throw through finally", `try { throw 1; } finally { print "f"; }`, nil, "f\n", "uncaught exception: 1\n", 70},
+		{"runtime error", `print -"a";`, nil, "", "Operand must be a number\n", 70},
+		{"syntax error", "throw;", nil, "", "[line 1] Error at ';': Expect expression.\n", 65},
+		{"step limit", `fun f(n) { return f(n + 1); } try { f(0); } catch (e) {}`, []string{"-max-steps", "100"}, "", "Step limit of 100 exceeded.\n", 75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut, status := runLox(t, tt.source, append([]string{"run"}, tt.args...)...)
+			if out != tt.wantOut || errOut != tt.wantErr || status != tt.wantStatus {
+				t.Errorf("got output %q, error %q and status %v, want %q, %q and %v",
+					out, errOut, status, tt.wantOut, tt.wantErr, tt.wantStatus)
+			}
+		})
+	}
+}
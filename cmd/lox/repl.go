@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+const (
+	replPrompt         = "> "
+	replContinuePrompt = "... "
+	maxHistory         = 1000
+)
+
+// repl reads inputs one at a time and runs them in interp, so declarations
+// carry over from one input to the next. Errors are reported without ending
+// the session. It returns when the input ends.
+func repl(interp *lox.Interpreter, cfg config) error {
+	readLine, addHistory := replInput(interp)
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil && addHistory != nil {
+		historyPath = filepath.Join(home, ".lox_history")
+		for _, line := range loadHistory(historyPath) {
+			addHistory(line)
+		}
+	}
+
+	var pending []string
+	for {
+		prompt := replPrompt
+		if len(pending) > 0 {
+			prompt = replContinuePrompt
+		}
+		line, err := readLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			pending = nil
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) != "" && addHistory != nil {
+			addHistory(line)
+			if historyPath != "" {
+				appendHistory(historyPath, line)
+			}
+		}
+		pending = append(pending, line)
+		source := strings.Join(pending, "\n")
+		if incomplete(source) {
+			continue
+		}
+		pending = nil
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		replEval(interp, cfg, source)
+	}
+}
+
+// replEval runs source, echoing the value when it is a bare expression. The
+// interpreter has already reported any error on the error stream.
+func replEval(interp *lox.Interpreter, cfg config, source string) {
+	ctx, cancel := evalContext(cfg)
+	defer cancel()
+	expr := strings.TrimSuffix(strings.TrimSpace(source), ";")
+	if _, err := interp.ParseExpression(expr); err == nil {
+		if value, err := interp.EvalContext(ctx, expr); err == nil {
+			fmt.Println(lox.Stringify(value))
+		}
+		return
+	}
+	interp.Run(ctx, source)
+}
+
+// replInput returns functions reading REPL lines and recording history, with
+// completion of keywords and globals.
+func replInput(interp *lox.Interpreter) (func(prompt string) (string, error), func(line string)) {
+	return lineInput(func(prefix string) []string {
+		keywords := make([]string, 0, len(lox.Keywords))
+		for kw := range lox.Keywords {
+			keywords = append(keywords, kw)
+		}
+		return completions(prefix, keywords, interp.Globals())
+	})
+}
+
+// lineInput returns functions reading lines and recording history. They use a
+// line editor offering complete's completions when standard input is a
+// terminal, and plain line reading without history otherwise.
+func lineInput(complete func(prefix string) []string) (func(prompt string) (string, error), func(line string)) {
+	if isTerminal(os.Stdin.Fd()) {
+		ed := newLineEditor(os.Stdin, os.Stdout, complete)
+		if restore, err := makeRaw(ed.fd); err == nil {
+			restore()
+			return ed.readLine, ed.addHistory
+		}
+	}
+	in := bufio.NewReader(os.Stdin)
+	readLine := func(prompt string) (string, error) {
+		line, err := in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	return readLine, nil
+}
+
+// incomplete reports whether source ends inside a string or with an unclosed
+// brace, parenthesis or bracket, so that the REPL should read another line.
+func incomplete(source string) bool {
+	lexer := lox.NewLexer(source)
+	depth := 0
+	for tok := lexer.Next(); tok.Type != lox.TokenEOF; tok = lexer.Next() {
+		switch tok.Type {
+		case lox.TokenLeftBrace, lox.TokenLeftParen, lox.TokenLeftBracket:
+			depth++
+		case lox.TokenRightBrace, lox.TokenRightParen, lox.TokenRightBracket:
+			depth--
+		}
+	}
+	for _, err := range lexer.Errors() {
+		var scanErr lox.ScanError
+		if errors.As(err, &scanErr) && scanErr.Message == "Unterminated string." {
+			return true
+		}
+	}
+	return depth > 0
+}
+
+func loadHistory(path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	return lines
+}
+
+// appendHistory adds line to the history file, dropping the oldest lines to
+// keep at most maxHistory. The file is replaced rather than rewritten in place,
+// so a crash cannot truncate it. Failing to save history is not worth
+// interrupting the session for, so errors are ignored.
+func appendHistory(path, line string) {
+	lines := append(loadHistory(path), strings.Split(line, "\n")...)
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
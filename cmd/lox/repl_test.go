@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendHistoryTrims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var old strings.Builder
+	for i := 1; i <= maxHistory+5; i++ {
+		fmt.Fprintf(&old, "line %v\n", i)
+	}
+	if err := os.WriteFile(path, []byte(old.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	appendHistory(path, "fun f() {\n}")
+
+	lines := loadHistory(path)
+	if len(lines) != maxHistory {
+		t.Fatalf("history has %v lines, want %v", len(lines), maxHistory)
+	}
+	// the two lines of the entry push out the seven oldest
+	if lines[0] != "line 8" {
+		t.Errorf("first line = %q, want %q", lines[0], "line 8")
+	}
+	if last := lines[len(lines)-2:]; !reflect.DeepEqual(last, []string{"fun f() {", "}"}) {
+		t.Errorf("last lines = %q, want the appended entry", last)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestAppendHistoryCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	appendHistory(path, "print 1;")
+	appendHistory(path, "print 2;")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "print 1;\nprint 2;\n" {
+		t.Errorf("history = %q, want both lines", b)
+	}
+}
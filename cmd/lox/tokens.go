@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// tokenRecord is one token in the json and tsv formats of tokenize. Error is
+// the message of the lexical error the token caused, if any.
+type tokenRecord struct {
+	Type    string `json:"type"`
+	Lexeme  string `json:"lexeme"`
+	Literal any    `json:"literal"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Comment bool   `json:"comment,omitempty"`
+	Illegal bool   `json:"illegal,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// writeTokens writes every token lexer produces, comments and illegal tokens
+// included, as a JSON array with one token per line or as tab separated
+// values under a header line.
+func writeTokens(w io.Writer, lexer *lox.Lexer, format string) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "json":
+		bw.WriteString("[\n")
+	case "tsv":
+		bw.WriteString("type\tlexeme\tliteral\tline\tcolumn\terror\n")
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	for first := true; ; first = false {
+		seen := len(lexer.Errors())
+		tok := lexer.Next()
+		rec := tokenRecord{
+			Type:    tok.Type.Name(),
+			Lexeme:  tok.Lexeme,
+			Literal: tok.Value(),
+			Line:    tok.Line,
+			Column:  tok.Column,
+			Comment: tok.Type == lox.TokenComment,
+			Illegal: tok.Type == lox.TokenIllegal,
+		}
+		if errs := lexer.Errors(); len(errs) > seen {
+			rec.Error = errorMessage(errs[seen])
+		}
+		if format == "json" {
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if !first {
+				bw.WriteString(",\n")
+			}
+			bw.WriteString("  ")
+			bw.Write(b)
+		} else {
+			literal := ""
+			if rec.Literal != nil {
+				literal = fmt.Sprint(rec.Literal)
+			}
+			fmt.Fprintf(bw, "%v\t%v\t%v\t%v\t%v\t%v\n", rec.Type, tsvEscape(rec.Lexeme), tsvEscape(literal),
+				rec.Line, rec.Column, tsvEscape(rec.Error))
+		}
+		if tok.Type == lox.TokenEOF {
+			break
+		}
+	}
+	if format == "json" {
+		bw.WriteString("\n]\n")
+	}
+	return bw.Flush()
+}
+
+// errorMessage returns the message of a ScanError without its line prefix.
+func errorMessage(err error) string {
+	var scanErr lox.ScanError
+	if errors.As(err, &scanErr) {
+		return scanErr.Message
+	}
+	return err.Error()
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvEscape escapes the characters that would break a tab separated field.
+func tsvEscape(s string) string {
+	return tsvEscaper.Replace(s)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestWriteTokens(t *testing.T) {
+	const source = "var s = \"a\tb\"; // note\nprint 1.50 @\n"
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "json",
+			want: `[
+  {"type":"VAR","lexeme":"var","literal":null,"line":1,"column":1},
+  {"type":"IDENTIFIER","lexeme":"s","literal":null,"line":1,"column":5},
+  {"type":"EQUAL","lexeme":"=","literal":null,"line":1,"column":7},
+  {"type":"STRING","lexeme":"\"a\tb\"","literal":"a\tb","line":1,"column":9},
+  {"type":"SEMICOLON","lexeme":";","literal":null,"line":1,"column":14},
+  {"type":"COMMENT","lexeme":"// note","literal":null,"line":1,"column":16,"comment":true},
+  {"type":"PRINT","lexeme":"print","literal":null,"line":2,"column":1},
+  {"type":"NUMBER","lexeme":"1.50","literal":1.5,"line":2,"column":7},
+  {"type":"ILLEGAL","lexeme":"@","literal":null,"line":2,"column":12,"illegal":true,"error":"Unexpected character: @"},
+  {"type":"EOF","lexeme":"","literal":null,"line":3,"column":1}
+]
+`,
+		},
+		{
+			format: "tsv",
+			want: "type\tlexeme\tliteral\tline\tcolumn\terror\n" +
+				"VAR\tvar\t\t1\t1\t\n" +
+				"IDENTIFIER\ts\t\t1\t5\t\n" +
+				"EQUAL\t=\t\t1\t7\t\n" +
+				"STRING\t\"a\\tb\"\ta\\tb\t1\t9\t\n" +
+				"SEMICOLON\t;\t\t1\t14\t\n" +
+				"COMMENT\t// note\t\t1\t16\t\n" +
+				"PRINT\tprint\t\t2\t1\t\n" +
+				"NUMBER\t1.50\t1.5\t2\t7\t\n" +
+				"ILLEGAL\t@\t\t2\t12\tUnexpected character: @\n" +
+				"EOF\t\t\t3\t1\t\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out strings.Builder
+			if err := writeTokens(&out, lox.NewLexer(source), tt.format); err != nil {
+				t.Fatalf("writeTokens: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteTokensUnknownFormat(t *testing.T) {
+	var out strings.Builder
+	if err := writeTokens(&out, lox.NewLexer("1"), "xml"); err == nil {
+		t.Error("writeTokens succeeded with an unknown format")
+	}
+}
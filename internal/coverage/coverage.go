@@ -0,0 +1,237 @@
+// Package coverage records which parts of a Lox script ran: the lines holding
+// statements, the functions, and both branches of each and and or operator
+// and of each try statement with a catch clause. It writes the result in the
+// LCOV format read by genhtml and most coverage services, and as a summary.
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// branchPoint is an operator or statement the script can take one of two
+// ways.
+type branchPoint struct {
+	pos   lox.Pos
+	taken [2]int64
+}
+
+type function struct {
+	name  string
+	line  int
+	calls int64
+}
+
+type funcKey struct {
+	name string
+	line int
+}
+
+// Coverage records the coverage of one script. It implements
+// lox.BranchObserver.
+type Coverage struct {
+	lox.NopObserver
+	file      string
+	lines     map[int]int64 // lines holding statements to the runs of them
+	branches  map[lox.Pos]*branchPoint
+	functions map[funcKey]*function
+}
+
+// New returns the coverage of block, a script read from file, before any of
+// it has run.
+func New(file string, block lox.BlockStmt) *Coverage {
+	c := &Coverage{
+		file:      file,
+		lines:     make(map[int]int64),
+		branches:  make(map[lox.Pos]*branchPoint),
+		functions: make(map[funcKey]*function),
+	}
+	lox.Inspect(block, func(node any) bool {
+		switch n := node.(type) {
+		case lox.BlockStmt:
+			return true // the statements inside are counted instead
+		case lox.BinaryExpr:
+			if n.Op.Type == lox.TokenAnd || n.Op.Type == lox.TokenOr {
+				pos := lox.Pos{Line: n.Op.Line, Column: n.Op.Column}
+				c.branches[pos] = &branchPoint{pos: pos}
+			}
+		case lox.TryStmt:
+			if n.Catch != nil {
+				c.branches[n.Pos] = &branchPoint{pos: n.Pos}
+			}
+		case lox.FunDeclStmt:
+			k := funcKey{n.Name.Literal, n.Line}
+			c.functions[k] = &function{name: k.name, line: k.line}
+		}
+		if s, ok := node.(lox.Statement); ok {
+			c.lines[s.Position().Line] = 0
+		}
+		return true
+	})
+	return c
+}
+
+func (c *Coverage) StmtEnter(e *lox.Evaluator, s lox.Statement) {
+	if _, ok := s.(lox.BlockStmt); ok {
+		return
+	}
+	c.lines[s.Position().Line]++
+}
+
+func (c *Coverage) CallEnter(e *lox.Evaluator, fn lox.Callable, args []lox.Value, line int) {
+	if f, ok := fn.(*lox.LoxFunction); ok {
+		if fn := c.functions[funcKey{f.Name(), f.Line()}]; fn != nil {
+			fn.calls++
+		}
+	}
+}
+
+func (c *Coverage) Branch(e *lox.Evaluator, pos lox.Pos, branch int) {
+	if b := c.branches[pos]; b != nil {
+		b.taken[branch]++
+	}
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, less func(a, b K) bool) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	return keys
+}
+
+func (c *Coverage) sortedLines() []int {
+	return sortedKeys(c.lines, func(a, b int) bool { return a < b })
+}
+
+func (c *Coverage) sortedBranches() []*branchPoint {
+	var branches []*branchPoint
+	for _, pos := range sortedKeys(c.branches, func(a, b lox.Pos) bool {
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	}) {
+		branches = append(branches, c.branches[pos])
+	}
+	return branches
+}
+
+func (c *Coverage) sortedFunctions() []*function {
+	var functions []*function
+	for _, k := range sortedKeys(c.functions, func(a, b funcKey) bool {
+		return a.line < b.line || a.line == b.line && a.name < b.name
+	}) {
+		functions = append(functions, c.functions[k])
+	}
+	return functions
+}
+
+// counts is the number of things found and hit.
+type counts struct {
+	found, hit int
+}
+
+func (n *counts) add(hit bool) {
+	n.found++
+	if hit {
+		n.hit++
+	}
+}
+
+func (n counts) String() string {
+	if n.found == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%% (%v/%v)", 100*float64(n.hit)/float64(n.found), n.hit, n.found)
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "TN:")
+	fmt.Fprintf(bw, "SF:%v\n", c.file)
+	var fns counts
+	for _, fn := range c.sortedFunctions() {
+		fmt.Fprintf(bw, "FN:%v,%v\n", fn.line, fn.name)
+	}
+	for _, fn := range c.sortedFunctions() {
+		fmt.Fprintf(bw, "FNDA:%v,%v\n", fn.calls, fn.name)
+		fns.add(fn.calls > 0)
+	}
+	fmt.Fprintf(bw, "FNF:%v\nFNH:%v\n", fns.found, fns.hit)
+	var branches counts
+	block, lastLine := 0, 0
+	for _, b := range c.sortedBranches() {
+		if b.pos.Line != lastLine {
+			block, lastLine = 0, b.pos.Line
+		}
+		reached := b.taken[0]+b.taken[1] > 0
+		for i, taken := range b.taken {
+			count := "-"
+			if reached {
+				count = fmt.Sprint(taken)
+			}
+			fmt.Fprintf(bw, "BRDA:%v,%v,%v,%v\n", b.pos.Line, block, i, count)
+			branches.add(taken > 0)
+		}
+		block++
+	}
+	fmt.Fprintf(bw, "BRF:%v\nBRH:%v\n", branches.found, branches.hit)
+	var lines counts
+	for _, line := range c.sortedLines() {
+		fmt.Fprintf(bw, "DA:%v,%v\n", line, c.lines[line])
+		lines.add(c.lines[line] > 0)
+	}
+	fmt.Fprintf(bw, "LF:%v\nLH:%v\n", lines.found, lines.hit)
+	fmt.Fprintln(bw, "end_of_record")
+	return bw.Flush()
+}
+
+// WriteSummary writes the percentages of lines, branches and functions that
+// ran, followed by the lines that did not.
+func (c *Coverage) WriteSummary(w io.Writer) error {
+	var lines, branches, fns counts
+	var missed []int
+	for _, line := range c.sortedLines() {
+		lines.add(c.lines[line] > 0)
+		if c.lines[line] == 0 {
+			missed = append(missed, line)
+		}
+	}
+	for _, b := range c.sortedBranches() {
+		for _, taken := range b.taken {
+			branches.add(taken > 0)
+		}
+	}
+	for _, fn := range c.sortedFunctions() {
+		fns.add(fn.calls > 0)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "file\tlines\tbranches\tfunctions\tmissed lines")
+	fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", c.file, lines, branches, fns, lineRanges(missed))
+	return tw.Flush()
+}
+
+// lineRanges formats sorted line numbers, joining consecutive ones into
+// ranges.
+func lineRanges(lines []int) string {
+	var parts []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprint(lines[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%v-%v", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,96 @@
+package coverage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/coverage"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+const source = `fun half(n) {
+  return n / 2;
+}
+fun unused() {
+  return nil or 0;
+}
+var a = nil or half(4);
+var b = a and half(a) and nil;
+try {
+  print a;
+} catch (e) {
+  print e;
+}
+`
+
+func run(t *testing.T) *coverage.Coverage {
+	t.Helper()
+	block, err := lox.New().Parse(source)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	c := coverage.New("script.lox", block)
+	interp := lox.New(lox.WithObserver(c), lox.WithStdout(io.Discard))
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return c
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t).WriteLCOV(&buf); err != nil {
+		t.Fatalf("WriteLCOV: %v", err)
+	}
+	want := `TN:
+SF:script.lox
+FN:1,half
+FN:4,unused
+FNDA:2,half
+FNDA:0,unused
+FNF:2
+FNH:1
+BRDA:5,0,0,-
+BRDA:5,0,1,-
+BRDA:7,0,0,0
+BRDA:7,0,1,1
+BRDA:8,0,0,0
+BRDA:8,0,1,1
+BRDA:8,1,0,0
+BRDA:8,1,1,1
+BRDA:9,0,0,1
+BRDA:9,0,1,0
+BRF:10
+BRH:4
+DA:1,1
+DA:2,2
+DA:4,1
+DA:5,0
+DA:7,1
+DA:8,1
+DA:9,1
+DA:10,1
+DA:12,0
+LF:9
+LH:7
+end_of_record
+`
+	if buf.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", buf.String(), want)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t).WriteSummary(&buf); err != nil {
+		t.Fatalf("WriteSummary: %v", err)
+	}
+	want := `file        lines        branches      functions    missed lines
+script.lox  77.8% (7/9)  40.0% (4/10)  50.0% (1/2)  5, 12
+`
+	if buf.String() != want {
+		t.Errorf("got\n%v\nwant\n%v", buf.String(), want)
+	}
+}
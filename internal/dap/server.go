@@ -0,0 +1,451 @@
+// Package dap implements a Debug Adapter Protocol server for Lox. It launches
+// one script for the client and drives it with the debugger package, so that
+// editors can set breakpoints, step, pause and inspect variables.
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/debugger"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/framing"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// Server is a debug adapter for the client at the other end of its streams.
+// The script runs on its own goroutine and only the single thread it
+// provides, with ID 1, is reported to the client.
+type Server struct {
+	r    *bufio.Reader
+	opts []lox.Option
+
+	mu  sync.Mutex // guards w and seq, used by the script's goroutine too
+	w   *bufio.Writer
+	seq int
+
+	dbg    *debugger.Debugger
+	launch launchArguments
+	// lineBase is the number of the client's first line
+	lineBase int
+	// handles are the scopes given to the client as variables references
+	// since the script last paused, numbered from 1
+	handles []*lox.Environment
+	// after runs once the response to the current request has been sent
+	after func()
+}
+
+// NewServer returns a server reading messages from r and writing them to w,
+// whose scripts run in interpreters configured by opts.
+func NewServer(r io.Reader, w io.Writer, opts ...lox.Option) *Server {
+	return &Server{
+		r:        bufio.NewReader(r),
+		w:        bufio.NewWriter(w),
+		opts:     opts,
+		lineBase: 1,
+	}
+}
+
+// Run serves the client until it disconnects.
+func (s *Server) Run() error {
+	for {
+		data, err := framing.Read(s.r)
+		if err != nil {
+			if s.dbg != nil {
+				s.dbg.Terminate()
+			}
+			return fmt.Errorf("read message: %w", err)
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("decode message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		resp := response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: true}
+		h, ok := handlers[req.Command]
+		if ok {
+			resp.Body, err = h(s, req.Arguments)
+		} else {
+			err = fmt.Errorf("unsupported command %q", req.Command)
+		}
+		if err != nil {
+			resp.Success, resp.Message, resp.Body = false, err.Error(), nil
+		}
+		if err := s.write(&resp, &resp.Seq); err != nil {
+			return err
+		}
+		if s.after != nil {
+			s.after()
+			s.after = nil
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+var handlers = map[string]func(*Server, json.RawMessage) (any, error){
+	"initialize":              (*Server).initialize,
+	"launch":                  (*Server).launchRequest,
+	"setBreakpoints":          (*Server).setBreakpoints,
+	"setExceptionBreakpoints": (*Server).ignore,
+	"configurationDone":       (*Server).configurationDone,
+	"threads":                 (*Server).threads,
+	"stackTrace":              (*Server).stackTrace,
+	"scopes":                  (*Server).scopes,
+	"variables":               (*Server).variables,
+	"continue":                (*Server).continueRequest,
+	"next":                    (*Server).next,
+	"stepIn":                  (*Server).stepIn,
+	"stepOut":                 (*Server).stepOut,
+	"pause":                   (*Server).pause,
+	"evaluate":                (*Server).evaluate,
+	"terminate":               (*Server).terminate,
+	"disconnect":              (*Server).terminate,
+}
+
+// write sends msg, numbering it through seq.
+func (s *Server) write(msg any, seq *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	*seq = s.seq
+	return framing.Write(s.w, msg)
+}
+
+func (s *Server) send(name string, body any) error {
+	ev := event{Type: "event", Event: name, Body: body}
+	return s.write(&ev, &ev.Seq)
+}
+
+func decode(args json.RawMessage, v any) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) debugger() (*debugger.Debugger, error) {
+	if s.dbg == nil {
+		return nil, errors.New("no program launched")
+	}
+	return s.dbg, nil
+}
+
+func (s *Server) ignore(json.RawMessage) (any, error) {
+	return nil, nil
+}
+
+func (s *Server) initialize(args json.RawMessage) (any, error) {
+	var a initializeArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.LinesStartAt1 != nil && !*a.LinesStartAt1 {
+		s.lineBase = 0
+	}
+	return map[string]any{
+		"supportsConfigurationDoneRequest": true,
+		"supportsConditionalBreakpoints":   true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+// launchRequest loads the program. It starts running once the client has
+// set its breakpoints and sent configurationDone.
+func (s *Server) launchRequest(args json.RawMessage) (any, error) {
+	if err := decode(args, &s.launch); err != nil {
+		return nil, err
+	}
+	if s.launch.Program == "" {
+		return nil, errors.New("launch: no program given")
+	}
+	source, err := os.ReadFile(s.launch.Program)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(slices.Clone(s.opts),
+		lox.WithStdout(outputWriter{s, "stdout"}),
+		lox.WithStderr(outputWriter{s, "stderr"}),
+		// standard input carries the protocol
+		lox.WithStdin(strings.NewReader("")),
+	)
+	dbg := debugger.New(opts...)
+	if err := dbg.Load(string(source)); err != nil {
+		return nil, err
+	}
+	s.dbg = dbg
+	s.after = func() {
+		s.send("initialized", nil)
+	}
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (any, error) {
+	var a setBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	same := sameFile(a.Source.Path, s.launch.Program)
+	if same {
+		dbg.ClearBreakpoints()
+	}
+	breakpoints := make([]breakpoint, len(a.Breakpoints))
+	for i, sbp := range a.Breakpoints {
+		breakpoints[i] = breakpoint{Line: sbp.Line, Source: a.Source}
+		if !same {
+			breakpoints[i].Message = "not the launched program"
+			continue
+		}
+		bp, err := dbg.AddBreakpoint(s.loxLine(sbp.Line), sbp.Condition)
+		if err != nil {
+			breakpoints[i].Message = err.Error()
+			continue
+		}
+		breakpoints[i].ID, breakpoints[i].Verified, breakpoints[i].Line = bp.ID, true, s.clientLine(bp.Line)
+	}
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func (s *Server) configurationDone(json.RawMessage) (any, error) {
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	s.after = func() {
+		if s.launch.NoDebug {
+			dbg.ClearBreakpoints()
+		}
+		dbg.Start(context.Background(), s.launch.StopOnEntry && !s.launch.NoDebug)
+		go s.forward(dbg)
+	}
+	return nil, nil
+}
+
+// forward reports the debugger's events to the client.
+func (s *Server) forward(dbg *debugger.Debugger) {
+	for ev := range dbg.Events() {
+		switch ev.Reason {
+		case debugger.ReasonExited:
+			code := 0
+			if ev.Err != nil {
+				code = 1
+			}
+			s.send("exited", map[string]any{"exitCode": code})
+			s.send("terminated", nil)
+		default:
+			body := map[string]any{
+				"reason":            string(ev.Reason),
+				"threadId":          1,
+				"allThreadsStopped": true,
+			}
+			switch ev.Reason {
+			case debugger.ReasonBreakpoint:
+				body["hitBreakpointIds"] = []int{ev.Breakpoint}
+			case debugger.ReasonWatch:
+				body["reason"] = "data breakpoint"
+				body["description"] = fmt.Sprintf("%v changed from %v to %v", ev.Watch, ev.Old, ev.New)
+			}
+			s.send("stopped", body)
+		}
+	}
+}
+
+func (s *Server) threads(json.RawMessage) (any, error) {
+	return map[string]any{"threads": []map[string]any{{"id": 1, "name": "main"}}}, nil
+}
+
+func (s *Server) stackTrace(args json.RawMessage) (any, error) {
+	var a stackTraceArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	frames, err := dbg.Frames()
+	if err != nil {
+		return nil, err
+	}
+	src := source{Name: filepath.Base(s.launch.Program), Path: s.launch.Program}
+	stack := []stackFrame{}
+	for i, f := range frames {
+		if i < a.StartFrame || a.Levels > 0 && i >= a.StartFrame+a.Levels {
+			continue
+		}
+		stack = append(stack, stackFrame{ID: i + 1, Name: f.Name, Source: src, Line: s.clientLine(max(f.Line, 1)), Column: 1})
+	}
+	return map[string]any{"stackFrames": stack, "totalFrames": len(frames)}, nil
+}
+
+// scopes lists the frame's scope and those enclosing it out to the globals.
+func (s *Server) scopes(args json.RawMessage) (any, error) {
+	var a scopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	frames, err := dbg.Frames()
+	if err != nil {
+		return nil, err
+	}
+	if a.FrameID < 1 || a.FrameID > len(frames) {
+		return nil, fmt.Errorf("no frame %v", a.FrameID)
+	}
+	scopes := []scope{}
+	for env := frames[a.FrameID-1].Env; env != nil; env = env.Outer() {
+		sc := scope{Name: "Closure", VariablesReference: s.handle(env)}
+		switch {
+		case env.Outer() == nil:
+			sc.Name = "Globals"
+		case len(scopes) == 0:
+			sc.Name, sc.PresentationHint = "Locals", "locals"
+		}
+		scopes = append(scopes, sc)
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) handle(env *lox.Environment) int {
+	s.handles = append(s.handles, env)
+	return len(s.handles)
+}
+
+func (s *Server) variables(args json.RawMessage) (any, error) {
+	var a variablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.handles) {
+		return nil, fmt.Errorf("no variables reference %v", a.VariablesReference)
+	}
+	env := s.handles[a.VariablesReference-1]
+	vars := []variable{}
+	for _, name := range env.Names() {
+		value, _ := env.Lookup(name)
+		vars = append(vars, variable{Name: name, Value: lox.Stringify(value)})
+	}
+	return map[string]any{"variables": vars}, nil
+}
+
+// resume runs step once the response has been sent, so that the client hears
+// of the request's success before the script next stops. The scopes handed
+// out while the script was paused are forgotten.
+func (s *Server) resume(step func(*debugger.Debugger) error) error {
+	dbg, err := s.debugger()
+	if err != nil {
+		return err
+	}
+	if !dbg.Paused() {
+		return debugger.ErrRunning
+	}
+	s.handles = nil
+	s.after = func() {
+		step(dbg)
+	}
+	return nil
+}
+
+func (s *Server) continueRequest(json.RawMessage) (any, error) {
+	if err := s.resume((*debugger.Debugger).Continue); err != nil {
+		return nil, err
+	}
+	return map[string]any{"allThreadsContinued": true}, nil
+}
+
+func (s *Server) next(json.RawMessage) (any, error) {
+	return nil, s.resume((*debugger.Debugger).StepOver)
+}
+
+func (s *Server) stepIn(json.RawMessage) (any, error) {
+	return nil, s.resume((*debugger.Debugger).StepIn)
+}
+
+func (s *Server) stepOut(json.RawMessage) (any, error) {
+	return nil, s.resume((*debugger.Debugger).StepOut)
+}
+
+func (s *Server) pause(json.RawMessage) (any, error) {
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	dbg.Pause()
+	return nil, nil
+}
+
+func (s *Server) evaluate(args json.RawMessage) (any, error) {
+	var a evaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	dbg, err := s.debugger()
+	if err != nil {
+		return nil, err
+	}
+	frame := 0
+	if a.FrameID != nil {
+		frame = *a.FrameID - 1
+	}
+	value, err := dbg.Evaluate(a.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"result": lox.Stringify(value), "variablesReference": 0}, nil
+}
+
+func (s *Server) terminate(json.RawMessage) (any, error) {
+	if s.dbg != nil {
+		s.dbg.Terminate()
+	}
+	return nil, nil
+}
+
+func (s *Server) loxLine(line int) int {
+	return line + 1 - s.lineBase
+}
+
+func (s *Server) clientLine(line int) int {
+	return line - 1 + s.lineBase
+}
+
+// outputWriter sends what the script writes to the client as output events.
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	if err := w.s.send("output", map[string]any{"category": w.category, "output": string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,219 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/dap"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/framing"
+)
+
+const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = add(1, 2);
+var y = add(x, 10);
+print y;
+`
+
+type message struct {
+	Type       string
+	Command    string
+	RequestSeq int `json:"request_seq"`
+	Success    bool
+	Message    string
+	Event      string
+	Body       json.RawMessage
+}
+
+type event struct {
+	name string
+	want string
+}
+
+// A step sends one request and expects a response whose body has the members
+// of want, or failure if fails is set, followed by events.
+type step struct {
+	command string
+	args    string
+	want    string
+	fails   bool
+	events  []event
+}
+
+func TestSession(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "add.lox")
+	if err := os.WriteFile(program, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stopped := func(reason string) []event {
+		return []event{{"stopped", fmt.Sprintf(`{"reason":%q,"threadId":1}`, reason)}}
+	}
+	stack := func(frames string) step {
+		return step{command: "stackTrace", args: `{"threadId":1}`, want: `{"stackFrames":` + frames + `}`}
+	}
+	steps := []step{
+		{command: "initialize", args: `{"linesStartAt1":true}`, want: `{"supportsConditionalBreakpoints":true}`},
+		{
+			command: "launch", args: fmt.Sprintf(`{"program":%q,"stopOnEntry":true}`, program),
+			events: []event{{"initialized", ""}},
+		},
+		{
+			command: "setBreakpoints",
+			args:    fmt.Sprintf(`{"source":{"path":%q},"breakpoints":[{"line":2,"condition":"a == 3"},{"line":40}]}`, program),
+			want:    `{"breakpoints":[{"id":1,"verified":true,"line":2},{"verified":false}]}`,
+		},
+		{command: "configurationDone", events: stopped("entry")},
+		stack(`[{"name":"script","line":1}]`),
+		{command: "stepIn", args: `{"threadId":1}`, events: stopped("step")},
+		stack(`[{"name":"script","line":5}]`),
+		{command: "stepIn", args: `{"threadId":1}`, events: stopped("step")},
+		stack(`[{"id":1,"name":"add","line":2},{"id":2,"name":"script","line":5}]`),
+		{
+			command: "continue", args: `{"threadId":1}`, want: `{"allThreadsContinued":true}`,
+			events: []event{{"stopped", `{"reason":"breakpoint","hitBreakpointIds":[1]}`}},
+		},
+		stack(`[{"name":"add","line":2},{"name":"script","line":6}]`),
+		{
+			command: "scopes", args: `{"frameId":1}`,
+			want: `{"scopes":[{"name":"Locals","presentationHint":"locals","variablesReference":1},{"name":"Globals","variablesReference":2}]}`,
+		},
+		{
+			command: "variables", args: `{"variablesReference":1}`,
+			want: `{"variables":[{"name":"a","value":"3"},{"name":"b","value":"10"}]}`,
+		},
+		{command: "variables", args: `{"variablesReference":3}`, fails: true},
+		{command: "evaluate", args: `{"expression":"a + b","frameId":1}`, want: `{"result":"13"}`},
+		{command: "evaluate", args: `{"expression":"x","frameId":2}`, want: `{"result":"3"}`},
+		{command: "evaluate", args: `{"expression":"a","frameId":2}`, fails: true},
+		{command: "next", args: `{"threadId":1}`, events: stopped("step")},
+		stack(`[{"name":"add","line":3},{"name":"script","line":6}]`),
+		{command: "evaluate", args: `{"expression":"sum"}`, want: `{"result":"13"}`},
+		{command: "stepOut", args: `{"threadId":1}`, events: stopped("step")},
+		stack(`[{"name":"script","line":7}]`),
+		{
+			command: "continue", args: `{"threadId":1}`,
+			events: []event{
+				{"output", `{"category":"stdout","output":"13\n"}`},
+				{"exited", `{"exitCode":0}`},
+				{"terminated", ""},
+			},
+		},
+		{command: "next", args: `{"threadId":1}`, fails: true},
+		{command: "disconnect"},
+	}
+
+	toServer, client := io.Pipe()
+	fromServer, server := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- dap.NewServer(toServer, server).Run()
+		server.Close()
+	}()
+	w, r := bufio.NewWriter(client), bufio.NewReader(fromServer)
+	read := func() message {
+		data, err := framing.Read(r)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+		return msg
+	}
+
+	// events that arrive while waiting for a response are checked after it
+	var pending []message
+	for seq, s := range steps {
+		seq++
+		req := map[string]any{"seq": seq, "type": "request", "command": s.command}
+		if s.args != "" {
+			req["arguments"] = json.RawMessage(s.args)
+		}
+		if err := framing.Write(w, req); err != nil {
+			t.Fatalf("%v: write: %v", s.command, err)
+		}
+		var resp message
+		for resp.Type != "response" {
+			if resp = read(); resp.Type == "event" {
+				pending = append(pending, resp)
+			}
+		}
+		switch {
+		case resp.RequestSeq != seq:
+			t.Fatalf("%v: got the response to request %v, want %v", s.command, resp.RequestSeq, seq)
+		case resp.Success == s.fails:
+			t.Fatalf("%v: success = %v (%v), want %v", s.command, resp.Success, resp.Message, !s.fails)
+		case s.want != "" && !contains(t, resp.Body, s.want):
+			t.Errorf("%v (request %v):\ngot  %s\nwant %s", s.command, seq, resp.Body, s.want)
+		}
+		for _, want := range s.events {
+			var ev message
+			if len(pending) > 0 {
+				ev, pending = pending[0], pending[1:]
+			} else {
+				ev = read()
+			}
+			if ev.Type != "event" || ev.Event != want.name {
+				t.Fatalf("%v: got %v %v%v, want the %v event", s.command, ev.Type, ev.Event, ev.Command, want.name)
+			}
+			if want.want != "" && !contains(t, ev.Body, want.want) {
+				t.Errorf("%v: %v event:\ngot  %s\nwant %s", s.command, want.name, ev.Body, want.want)
+			}
+		}
+		if len(pending) > 0 {
+			t.Fatalf("%v: unexpected %v event", s.command, pending[0].Event)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+// contains reports whether the JSON value got has every object member of the
+// JSON value want, comparing everything else exactly.
+func contains(t *testing.T, got json.RawMessage, want string) bool {
+	t.Helper()
+	var g, w any
+	json.Unmarshal(got, &g)
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad want %s: %v", want, err)
+	}
+	return subset(g, w)
+}
+
+func subset(got, want any) bool {
+	switch want := want.(type) {
+	case map[string]any:
+		got, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if !subset(got[k], v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		got, ok := got.([]any)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !subset(got[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
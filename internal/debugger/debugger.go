@@ -0,0 +1,444 @@
+// Package debugger runs a Lox script under the control of a front end, such
+// as the Debug Adapter Protocol server or the terminal debugger. It observes
+// the evaluator and pauses the script before a statement at a breakpoint,
+// after a step, on request, or when a watched variable changes. While the
+// script is paused the front end can inspect its call stack and scopes and
+// evaluate expressions in them.
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// Reason tells why an Event was sent.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+	ReasonPause      Reason = "pause"
+	ReasonWatch      Reason = "watch"
+	ReasonExited     Reason = "exited"
+)
+
+// Event reports that the script paused or, with ReasonExited, finished with
+// Err.
+type Event struct {
+	Reason Reason
+	Line   int
+	// Breakpoint is the ID of the breakpoint that was hit.
+	Breakpoint int
+	// Watch is the watched variable that changed from Old to New.
+	Watch    string
+	Old, New string
+	Err      error
+}
+
+// Breakpoint pauses the script before the first statement on Line, if
+// Condition is empty or evaluates to a true value there.
+type Breakpoint struct {
+	ID        int
+	Line      int
+	Condition string
+	Hits      int
+	cond      lox.Expression
+}
+
+// Frame is a function call on the stack, or the script's top level.
+type Frame struct {
+	Name string
+	// Line is the line of the statement the frame is executing, or 0 before
+	// a called function's first statement.
+	Line int
+	// Env is the scope the frame is executing in. It may only be read while
+	// the script is paused.
+	Env *lox.Environment
+}
+
+// ErrRunning is returned by the methods that need a paused script when the
+// script is running or has finished.
+var ErrRunning = errors.New("the script is not paused")
+
+type stepMode int
+
+const (
+	modeRun stepMode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+)
+
+// command is run by the paused script's goroutine on behalf of the front end.
+type command struct {
+	fn     func(e *lox.Evaluator)
+	resume bool
+	done   chan struct{}
+}
+
+type watch struct {
+	value   string
+	defined bool
+}
+
+// Debugger controls one run of a script. It implements lox.Observer.
+type Debugger struct {
+	lox.NopObserver
+	interp *lox.Interpreter
+	prog   *lox.Program
+	lines  map[int]bool // lines that start a statement
+	cancel context.CancelFunc
+	events chan Event
+
+	mu          sync.Mutex // guards the fields below, set while the script runs
+	breakpoints map[int]*Breakpoint
+	nextID      int
+	watches     map[string]*watch
+	paused      bool
+	pause       atomic.Bool
+
+	// owned by the script's goroutine
+	commands   chan command
+	frames     []Frame
+	mode       stepMode
+	stepDepth  int
+	evaluating bool
+}
+
+// New returns a debugger for an interpreter configured by opts.
+func New(opts ...lox.Option) *Debugger {
+	d := &Debugger{
+		events:      make(chan Event, 1),
+		breakpoints: make(map[int]*Breakpoint),
+		watches:     make(map[string]*watch),
+		commands:    make(chan command),
+	}
+	d.interp = lox.New(append(opts, lox.WithObserver(d))...)
+	return d
+}
+
+// Load parses the script to debug.
+func (d *Debugger) Load(source string) error {
+	block, err := d.interp.Parse(source)
+	if err != nil {
+		return err
+	}
+	d.prog = lox.NewProgram(block)
+	d.lines = make(map[int]bool)
+	addLines(d.lines, block.Body)
+	return nil
+}
+
+// addLines records the lines of the statements in body that the debugger can
+// pause at.
+func addLines(lines map[int]bool, body []lox.Statement) {
+	for _, s := range body {
+		switch s := s.(type) {
+		case lox.BlockStmt:
+			addLines(lines, s.Body)
+			continue
+		case lox.FunDeclStmt:
+			addLines(lines, s.Body)
+		case lox.TryStmt:
+			addLines(lines, s.Body.Body)
+			if s.Catch != nil {
+				addLines(lines, s.Catch.Body)
+			}
+			if s.Finally != nil {
+				addLines(lines, s.Finally.Body)
+			}
+		}
+		lines[s.Position().Line] = true
+	}
+}
+
+// Start runs the loaded script on a new goroutine, paused before its first
+// statement if stopOnEntry is set. Events reports its progress.
+func (d *Debugger) Start(ctx context.Context, stopOnEntry bool) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	if stopOnEntry {
+		d.mode = modeStepIn
+	}
+	d.frames = []Frame{{Name: "script"}}
+	go func() {
+		err := d.interp.Exec(ctx, d.prog)
+		d.events <- Event{Reason: ReasonExited, Err: err}
+		close(d.events)
+	}()
+}
+
+// Events returns the channel the debugger sends events on. It is closed after
+// the script exits.
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+// AddBreakpoint sets a breakpoint at the first line from line on that starts a
+// statement. It fails if there is none or condition does not parse.
+func (d *Debugger) AddBreakpoint(line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{Condition: condition}
+	if condition != "" {
+		cond, err := d.interp.ParseExpression(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.cond = cond
+	}
+	last := 0
+	for l := range d.lines {
+		last = max(last, l)
+	}
+	for bp.Line = line; !d.lines[bp.Line]; bp.Line++ {
+		if bp.Line > last {
+			return nil, fmt.Errorf("no statement at or after line %v", line)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextID++
+	bp.ID = d.nextID
+	d.breakpoints[bp.Line] = bp
+	return bp, nil
+}
+
+// ClearBreakpoints removes every breakpoint.
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	clear(d.breakpoints)
+}
+
+// Watch pauses the script whenever a variable called name is given a value
+// that prints differently from the one it last had.
+func (d *Debugger) Watch(name string) {
+	w := &watch{}
+	if value, err := d.Evaluate(name, 0); err == nil {
+		w.value, w.defined = lox.Stringify(value), true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watches[name] = w
+}
+
+// Pause asks the running script to pause before its next statement.
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Paused reports whether the script is paused.
+func (d *Debugger) Paused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused
+}
+
+// Continue resumes the paused script.
+func (d *Debugger) Continue() error {
+	return d.resume(modeRun)
+}
+
+// StepIn resumes the paused script until the next statement, in whichever
+// function it is.
+func (d *Debugger) StepIn() error {
+	return d.resume(modeStepIn)
+}
+
+// StepOver resumes the paused script until the next statement in the current
+// function or one of its callers.
+func (d *Debugger) StepOver() error {
+	return d.resume(modeStepOver)
+}
+
+// StepOut resumes the paused script until the current function returns.
+func (d *Debugger) StepOut() error {
+	return d.resume(modeStepOut)
+}
+
+// Terminate stops the script, whether it is running or paused.
+func (d *Debugger) Terminate() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	d.resume(modeRun)
+}
+
+// Frames returns the call stack of the paused script, innermost first.
+func (d *Debugger) Frames() ([]Frame, error) {
+	var frames []Frame
+	err := d.do(false, func(*lox.Evaluator) {
+		for i := len(d.frames) - 1; i >= 0; i-- {
+			frames = append(frames, d.frames[i])
+		}
+	})
+	return frames, err
+}
+
+// Evaluate evaluates expr in the scope of the paused script's frame, counted
+// from the innermost. Statements run by functions it calls do not pause.
+func (d *Debugger) Evaluate(expr string, frame int) (lox.Value, error) {
+	parsed, err := d.interp.ParseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	var value lox.Value
+	var evalErr error
+	err = d.do(false, func(e *lox.Evaluator) {
+		if frame < 0 || frame >= len(d.frames) {
+			evalErr = fmt.Errorf("no frame %v", frame)
+			return
+		}
+		value, evalErr = d.eval(e, d.frames[len(d.frames)-1-frame].Env, parsed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, evalErr
+}
+
+func (d *Debugger) resume(mode stepMode) error {
+	return d.do(true, func(*lox.Evaluator) {
+		d.mode = mode
+		d.stepDepth = len(d.frames)
+	})
+}
+
+// do runs fn on the paused script's goroutine, then resumes the script if
+// resume is set.
+func (d *Debugger) do(resume bool, fn func(e *lox.Evaluator)) error {
+	d.mu.Lock()
+	paused := d.paused
+	if resume {
+		d.paused = false
+	}
+	d.mu.Unlock()
+	if !paused {
+		return ErrRunning
+	}
+	cmd := command{fn: fn, resume: resume, done: make(chan struct{})}
+	d.commands <- cmd
+	<-cmd.done
+	return nil
+}
+
+func (d *Debugger) eval(e *lox.Evaluator, env *lox.Environment, expr lox.Expression) (lox.Value, error) {
+	d.evaluating = true
+	defer func() {
+		d.evaluating = false
+	}()
+	return e.EvalIn(env, expr)
+}
+
+// stop pauses the script and serves the front end's commands until one
+// resumes it.
+func (d *Debugger) stop(e *lox.Evaluator, ev Event) {
+	e.Flush()
+	if top := &d.frames[len(d.frames)-1]; top.Env == nil {
+		top.Env = e.Env()
+	}
+	d.pause.Store(false)
+	d.mode = modeRun
+	d.mu.Lock()
+	d.paused = true
+	d.mu.Unlock()
+	d.events <- ev
+	for cmd := range d.commands {
+		cmd.fn(e)
+		close(cmd.done)
+		if cmd.resume {
+			return
+		}
+	}
+}
+
+func (d *Debugger) StmtEnter(e *lox.Evaluator, s lox.Statement) {
+	if d.evaluating {
+		return
+	}
+	if _, ok := s.(lox.BlockStmt); ok {
+		return // pause at the statements inside instead
+	}
+	line, depth := s.Position().Line, len(d.frames)
+	top := &d.frames[depth-1]
+	// a line is entered once however many statements it holds
+	entered := line != top.Line
+	top.Line, top.Env = line, e.Env()
+	if !entered {
+		return
+	}
+
+	ev := Event{Line: line}
+	switch {
+	case d.mode == modeStepIn,
+		d.mode == modeStepOver && depth <= d.stepDepth,
+		d.mode == modeStepOut && depth < d.stepDepth:
+		ev.Reason = ReasonStep
+		if d.stepDepth == 0 {
+			ev.Reason = ReasonEntry
+		}
+	case d.pause.Load():
+		ev.Reason = ReasonPause
+	default:
+		d.mu.Lock()
+		bp := d.breakpoints[line]
+		d.mu.Unlock()
+		if bp == nil {
+			return
+		}
+		if bp.cond != nil {
+			value, err := d.eval(e, e.Env(), bp.cond)
+			if err == nil && !lox.Truthy(value) {
+				return
+			}
+		}
+		bp.Hits++
+		ev.Reason, ev.Breakpoint = ReasonBreakpoint, bp.ID
+	}
+	d.stop(e, ev)
+}
+
+func (d *Debugger) CallEnter(e *lox.Evaluator, fn lox.Callable, args []lox.Value, line int) {
+	if f, ok := fn.(*lox.LoxFunction); ok {
+		d.frames = append(d.frames, Frame{Name: f.Name()})
+	}
+}
+
+func (d *Debugger) CallExit(e *lox.Evaluator, fn lox.Callable, result lox.Value, err error) {
+	if _, ok := fn.(*lox.LoxFunction); ok {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+}
+
+func (d *Debugger) Define(e *lox.Evaluator, env *lox.Environment, name string, value lox.Value) {
+	d.changed(e, name, value)
+}
+
+func (d *Debugger) Assign(e *lox.Evaluator, env *lox.Environment, name string, value lox.Value) {
+	d.changed(e, name, value)
+}
+
+// changed pauses the script if name is watched and now prints differently.
+func (d *Debugger) changed(e *lox.Evaluator, name string, value lox.Value) {
+	if d.evaluating {
+		return
+	}
+	d.mu.Lock()
+	w, ok := d.watches[name]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	old, defined := w.value, w.defined
+	w.value, w.defined = lox.Stringify(value), true
+	d.mu.Unlock()
+	if defined && old == w.value {
+		return
+	}
+	d.stop(e, Event{Reason: ReasonWatch, Line: d.frames[len(d.frames)-1].Line, Watch: name, Old: old, New: w.value})
+}
@@ -0,0 +1,203 @@
+package debugger_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/debugger"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var x = add(1, 2);
+var y = add(x, 10);
+print y;
+`
+
+type breakpoint struct {
+	line      int
+	condition string
+}
+
+// A stop is where the script is expected to pause, with its call stack as
+// name:line innermost first and the values of expressions evaluated there.
+// The test resumes the script with then.
+type stop struct {
+	reason debugger.Reason
+	line   int
+	frames []string
+	eval   map[string]string
+	then   func(*debugger.Debugger) error
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		stopOnEntry bool
+		breakpoints []breakpoint
+		stops       []stop
+	}{
+		{
+			name:        "conditional breakpoint",
+			breakpoints: []breakpoint{{2, "a == 3"}},
+			stops: []stop{
+				{
+					reason: debugger.ReasonBreakpoint, line: 2,
+					frames: []string{"add:2", "script:6"},
+					eval:   map[string]string{"a + b": "13", "x": "3"},
+					then:   (*debugger.Debugger).Continue,
+				},
+			},
+		},
+		{
+			name:        "false condition",
+			breakpoints: []breakpoint{{2, "a == 100"}},
+		},
+		{
+			name:        "breakpoint moves to the next statement",
+			breakpoints: []breakpoint{{4, ""}},
+			stops: []stop{
+				{reason: debugger.ReasonBreakpoint, line: 5, then: (*debugger.Debugger).Continue},
+			},
+		},
+		{
+			name:        "next steps over calls",
+			stopOnEntry: true,
+			stops: []stop{
+				{reason: debugger.ReasonEntry, line: 1, frames: []string{"script:1"}, then: (*debugger.Debugger).StepOver},
+				{reason: debugger.ReasonStep, line: 5, then: (*debugger.Debugger).StepOver},
+				{reason: debugger.ReasonStep, line: 6, eval: map[string]string{"x": "3"}, then: (*debugger.Debugger).StepOver},
+				{reason: debugger.ReasonStep, line: 7, eval: map[string]string{"y": "13"}, then: (*debugger.Debugger).StepOver},
+			},
+		},
+		{
+			name:        "stepIn enters calls",
+			stopOnEntry: true,
+			stops: []stop{
+				{reason: debugger.ReasonEntry, line: 1, then: (*debugger.Debugger).StepIn},
+				{reason: debugger.ReasonStep, line: 5, then: (*debugger.Debugger).StepIn},
+				{reason: debugger.ReasonStep, line: 2, frames: []string{"add:2", "script:5"}, eval: map[string]string{"a": "1"}, then: (*debugger.Debugger).StepIn},
+				{reason: debugger.ReasonStep, line: 3, eval: map[string]string{"sum": "3"}, then: (*debugger.Debugger).StepIn},
+				{reason: debugger.ReasonStep, line: 6, frames: []string{"script:6"}, then: (*debugger.Debugger).Continue},
+			},
+		},
+		{
+			name:        "stepOut returns to the caller",
+			breakpoints: []breakpoint{{3, ""}},
+			stops: []stop{
+				{reason: debugger.ReasonBreakpoint, line: 3, eval: map[string]string{"sum": "3"}, then: (*debugger.Debugger).StepOut},
+				{reason: debugger.ReasonStep, line: 6, frames: []string{"script:6"}, eval: map[string]string{"x": "3"}, then: (*debugger.Debugger).Continue},
+				{reason: debugger.ReasonBreakpoint, line: 3, frames: []string{"add:3", "script:6"}, eval: map[string]string{"sum": "13"}, then: (*debugger.Debugger).Continue},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			d := debugger.New(lox.WithStdout(&out))
+			if err := d.Load(source); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			for _, bp := range tt.breakpoints {
+				if _, err := d.AddBreakpoint(bp.line, bp.condition); err != nil {
+					t.Fatalf("AddBreakpoint: %v", err)
+				}
+			}
+			d.Start(context.Background(), tt.stopOnEntry)
+			defer d.Terminate()
+			for i, want := range tt.stops {
+				ev := nextEvent(t, d)
+				if ev.Reason != want.reason || ev.Line != want.line {
+					t.Fatalf("stop %v: got %v at line %v, want %v at line %v", i, ev.Reason, ev.Line, want.reason, want.line)
+				}
+				if want.frames != nil {
+					frames, err := d.Frames()
+					if err != nil {
+						t.Fatalf("stop %v: Frames: %v", i, err)
+					}
+					var got []string
+					for _, f := range frames {
+						got = append(got, fmt.Sprintf("%v:%v", f.Name, f.Line))
+					}
+					if !reflect.DeepEqual(got, want.frames) {
+						t.Errorf("stop %v: frames = %v, want %v", i, got, want.frames)
+					}
+				}
+				for expr, wantValue := range want.eval {
+					value, err := d.Evaluate(expr, 0)
+					if err != nil {
+						t.Errorf("stop %v: Evaluate(%q): %v", i, expr, err)
+					} else if got := lox.Stringify(value); got != wantValue {
+						t.Errorf("stop %v: %v = %v, want %v", i, expr, got, wantValue)
+					}
+				}
+				if err := want.then(d); err != nil {
+					t.Fatalf("stop %v: resume: %v", i, err)
+				}
+			}
+			if ev := nextEvent(t, d); ev.Reason != debugger.ReasonExited || ev.Err != nil {
+				t.Fatalf("got %v at line %v (error %v), want the script to exit", ev.Reason, ev.Line, ev.Err)
+			}
+			if out.String() != "13\n" {
+				t.Errorf("output = %q, want %q", out.String(), "13\n")
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	d := debugger.New(lox.WithStdout(io.Discard), lox.WithStderr(io.Discard))
+	if err := d.Load(source); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := d.Evaluate("x", 0); err != debugger.ErrRunning {
+		t.Errorf("Evaluate before Start: %v, want ErrRunning", err)
+	}
+	if _, err := d.AddBreakpoint(2, "a =="); err == nil {
+		t.Error("AddBreakpoint with a malformed condition succeeded")
+	}
+	if _, err := d.AddBreakpoint(8, ""); err == nil {
+		t.Error("AddBreakpoint after the last statement succeeded")
+	}
+	d.AddBreakpoint(2, "")
+	d.Start(context.Background(), false)
+	defer d.Terminate()
+	nextEvent(t, d)
+	tests := []struct {
+		expr  string
+		frame int
+	}{
+		{"sum", 0},    // not yet declared
+		{"a", 1},      // a parameter of the frame above
+		{"1 +", 0},    // malformed
+		{"x", 2},      // no such frame
+		{"-\"s\"", 0}, // fails at run time
+	}
+	for _, tt := range tests {
+		if value, err := d.Evaluate(tt.expr, tt.frame); err == nil {
+			t.Errorf("Evaluate(%q, %v) = %v, want an error", tt.expr, tt.frame, lox.Stringify(value))
+		}
+	}
+	if !d.Paused() {
+		t.Error("the script resumed after failed evaluations")
+	}
+}
+
+func nextEvent(t *testing.T, d *debugger.Debugger) debugger.Event {
+	t.Helper()
+	select {
+	case ev := <-d.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the script to stop")
+		return debugger.Event{}
+	}
+}
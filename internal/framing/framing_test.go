@@ -0,0 +1,52 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"body", "Content-Length: 2\r\n\r\n{}", "{}", false},
+		{"extra headers", "Content-Type: x\r\ncontent-length: 3\r\n\r\n[1]", "[1]", false},
+		{"missing length", "Content-Type: x\r\n\r\n{}", "", true},
+		{"malformed header", "Content-Length 2\r\n\r\n{}", "", true},
+		{"negative length", "Content-Length: -1\r\n\r\n", "", true},
+		{"length over the limit", fmt.Sprintf("Content-Length: %v\r\n\r\n", MaxLength+1), "", true},
+		{"huge length", "Content-Length: 99999999999\r\n\r\n", "", true},
+		{"short body", "Content-Length: 5\r\n\r\n{}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read error = %v, want error %v", err, tt.wantErr)
+			}
+			if string(body) != tt.want {
+				t.Errorf("Read = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(bufio.NewWriter(&buf), map[string]int{"id": 1}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	body, err := Read(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if string(body) != `{"id":1}` {
+		t.Errorf("Read = %q, want %q", body, `{"id":1}`)
+	}
+}
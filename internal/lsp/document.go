@@ -0,0 +1,266 @@
+package lsp
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// document is an open text document and what the server knows about it.
+// Lox positions count lines and runes from 1, while protocol positions count
+// lines from 0 and characters in UTF-16 code units, or in runes when the
+// client agrees to UTF-32.
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []int // byte offset of the start of each line
+	utf16   bool
+
+	tokens      []lox.Token     // every token but EOF, comments and illegal tokens included
+	res         *lox.Resolution // nil if the document does not parse
+	diagnostics []diagnostic
+}
+
+func newDocument(uri string, version int, text string, utf16 bool) *document {
+	d := &document{uri: uri, version: version, utf16: utf16}
+	d.setText(text)
+	return d
+}
+
+func (d *document) setText(text string) {
+	d.text = text
+	d.lines = append(d.lines[:0], 0)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+}
+
+// apply makes an edit sent by the client.
+func (d *document) apply(c contentChange) {
+	if c.Range == nil {
+		d.setText(c.Text)
+		return
+	}
+	start, end := d.offset(c.Range.Start), d.offset(c.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	d.setText(d.text[:start] + c.Text + d.text[end:])
+}
+
+// line returns the text of line n, counted from 0, without its line ending.
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	end := len(d.text)
+	if n+1 < len(d.lines) {
+		end = d.lines[n+1] - 1
+	}
+	return d.text[d.lines[n]:end]
+}
+
+// units returns the length of r in the position encoding.
+func (d *document) units(r rune) int {
+	if d.utf16 && r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// width returns the length of s in the position encoding.
+func (d *document) width(s string) int {
+	if !d.utf16 {
+		return utf8.RuneCountInString(s)
+	}
+	n := 0
+	for _, r := range s {
+		n += d.units(r)
+	}
+	return n
+}
+
+// offset returns the byte offset of p, clamped to the document.
+func (d *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	line := d.line(p.Line)
+	n := 0
+	for i, r := range line {
+		if n >= p.Character {
+			return d.lines[p.Line] + i
+		}
+		n += d.units(r)
+	}
+	return d.lines[p.Line] + len(line)
+}
+
+// position converts a Lox line and column to a protocol position. A column
+// of 0 stands for the end of the line.
+func (d *document) position(line, column int) position {
+	text := d.line(line - 1)
+	if column <= 0 {
+		return position{line - 1, d.width(text)}
+	}
+	n, runes := 0, 0
+	for _, r := range text {
+		if runes == column-1 {
+			break
+		}
+		n += d.units(r)
+		runes++
+	}
+	return position{line - 1, n + column - 1 - runes}
+}
+
+// pos converts a protocol position to a Lox position.
+func (d *document) pos(p position) lox.Pos {
+	offset := d.offset(p)
+	line := max(min(p.Line, len(d.lines)-1), 0)
+	return lox.Pos{Line: line + 1, Column: utf8.RuneCountInString(d.text[d.lines[line]:offset]) + 1}
+}
+
+// nameRange returns the range of name written at pos.
+func (d *document) nameRange(pos lox.Pos, name string) rng {
+	return rng{d.position(pos.Line, pos.Column), d.position(pos.Line, pos.Column+utf8.RuneCountInString(name))}
+}
+
+// tokenRange returns the range of tok, which may span lines.
+func (d *document) tokenRange(tok lox.Token) rng {
+	lines := strings.Split(tok.Lexeme, "\n")
+	if len(lines) == 1 {
+		return d.nameRange(tok.Position(), tok.Lexeme)
+	}
+	last := lines[len(lines)-1]
+	endLine := tok.Line + len(lines) - 1
+	return rng{d.position(tok.Line, tok.Column), d.position(endLine, utf8.RuneCountInString(last)+1)}
+}
+
+// analyze lexes, parses and resolves the document, recording its
+// diagnostics. builtins names the globals defined by the interpreter.
+func (d *document) analyze(builtins []string) {
+	d.tokens = d.tokens[:0]
+	d.res = nil
+	d.diagnostics = []diagnostic{}
+	lexer := lox.NewLexer(d.text)
+	var parsed []lox.Token
+	for {
+		seen := len(lexer.Errors())
+		tok := lexer.Next()
+		if errs := lexer.Errors(); len(errs) > seen {
+			d.diagnostics = append(d.diagnostics, diagnostic{
+				Range:    d.tokenRange(tok),
+				Severity: severityError,
+				Source:   "lox",
+				Message:  scanMessage(errs[seen]),
+			})
+		}
+		if tok.Type != lox.TokenIllegal {
+			// the lexer has reported illegal tokens, so the parser skips them
+			parsed = append(parsed, tok)
+		}
+		if tok.Type == lox.TokenEOF {
+			break
+		}
+		d.tokens = append(d.tokens, tok)
+	}
+
+	block, err := lox.NewParser(parsed, nil).Parse()
+	if err != nil {
+		var parseErr lox.ParseError
+		if errors.As(err, &parseErr) {
+			r := d.nameRange(lox.Pos{Line: parseErr.Line, Column: parseErr.Column}, parseErr.Where)
+			if parseErr.Column == 0 {
+				r.Start = r.End
+			}
+			d.diagnostics = append(d.diagnostics, diagnostic{Range: r, Severity: severityError, Source: "lox", Message: parseErr.Message})
+		}
+		return
+	}
+	d.res = lox.Resolve(block, builtins)
+	for _, e := range d.res.Errors {
+		severity := severityError
+		if e.Warning {
+			severity = severityWarning
+		}
+		d.diagnostics = append(d.diagnostics, diagnostic{Range: d.nameRange(e.Pos, e.Name), Severity: severity, Source: "lox", Message: e.Message})
+	}
+}
+
+// scanMessage returns the message of a ScanError without its line prefix.
+func scanMessage(err error) string {
+	var scanErr lox.ScanError
+	if errors.As(err, &scanErr) {
+		return scanErr.Message
+	}
+	return err.Error()
+}
+
+// identifierAt returns the identifier token at or just after p.
+func (d *document) identifierAt(p position) (lox.Token, bool) {
+	pos := d.pos(p)
+	for _, tok := range d.tokens {
+		if tok.Type != lox.TokenIdentifier || tok.Line != pos.Line {
+			continue
+		}
+		if tok.Column <= pos.Column && pos.Column <= tok.Column+utf8.RuneCountInString(tok.Lexeme) {
+			return tok, true
+		}
+	}
+	return lox.Token{}, false
+}
+
+// symbolAt returns the symbol named at p.
+func (d *document) symbolAt(p position) (*lox.Symbol, lox.Token) {
+	if d.res == nil {
+		return nil, lox.Token{}
+	}
+	tok, ok := d.identifierAt(p)
+	if !ok {
+		return nil, lox.Token{}
+	}
+	return d.res.SymbolAt(tok.Position()), tok
+}
+
+// statementEnd returns the last token of the statement starting at pos: the
+// semicolon ending it, or the brace closing its body.
+func (d *document) statementEnd(pos lox.Pos) lox.Token {
+	depth := 0
+	start := -1
+	for i, tok := range d.tokens {
+		if tok.Position() == pos {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return lox.Token{}
+	}
+	for _, tok := range d.tokens[start:] {
+		switch tok.Type {
+		case lox.TokenLeftParen, lox.TokenLeftBrace, lox.TokenLeftBracket:
+			depth++
+		case lox.TokenRightParen, lox.TokenRightBracket:
+			depth--
+		case lox.TokenRightBrace:
+			depth--
+			if depth == 0 {
+				return tok
+			}
+		case lox.TokenSemiColon:
+			if depth == 0 {
+				return tok
+			}
+		}
+	}
+	return d.tokens[len(d.tokens)-1]
+}
@@ -0,0 +1,443 @@
+// Package lsp implements a Language Server Protocol server for Lox. It speaks
+// JSON-RPC over a pair of streams, usually standard input and output, and
+// publishes diagnostics and answers hover, definition, references, document
+// symbol and semantic token requests for the documents the client opens.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/framing"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// Server is a language server for the client at the other end of its
+// streams. It handles one message at a time.
+type Server struct {
+	r        *bufio.Reader
+	w        *bufio.Writer
+	docs     map[string]*document
+	builtins []string
+	utf16    bool
+
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a server reading messages from r and writing them to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		r:        bufio.NewReader(r),
+		w:        bufio.NewWriter(w),
+		docs:     make(map[string]*document),
+		builtins: lox.New().Globals(),
+		utf16:    true,
+	}
+}
+
+// Run serves the client until it sends exit. It returns an error if the
+// connection fails or closes, or the client exits without shutting the server
+// down first.
+func (s *Server) Run() error {
+	for {
+		data, err := framing.Read(s.r)
+		if err != nil {
+			if err == io.EOF && s.shutdown {
+				return nil
+			}
+			return fmt.Errorf("read message: %w", err)
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			if err := s.reply(json.RawMessage("null"), nil, &rpcError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(req.Method, req.Params, req.ID != nil)
+		if req.ID == nil {
+			continue // notifications get no response
+		}
+		if err := s.reply(*req.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+var handlers = map[string]func(*Server, json.RawMessage) (any, error){
+	"initialize":                       (*Server).initialize,
+	"initialized":                      (*Server).ignore,
+	"shutdown":                         (*Server).shutdownRequest,
+	"textDocument/didOpen":             (*Server).didOpen,
+	"textDocument/didChange":           (*Server).didChange,
+	"textDocument/didClose":            (*Server).didClose,
+	"textDocument/didSave":             (*Server).ignore,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/references":          (*Server).references,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+}
+
+func (s *Server) handle(method string, params json.RawMessage, isRequest bool) (any, error) {
+	switch {
+	case method == "initialize":
+	case !s.initialized:
+		return nil, &rpcError{codeServerNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &rpcError{codeInvalidRequest, "server is shutting down"}
+	}
+	h, ok := handlers[method]
+	if !ok {
+		if isRequest {
+			return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %v", method)}
+		}
+		return nil, nil // including $/ notifications such as $/cancelRequest
+	}
+	return h(s, params)
+}
+
+func (s *Server) reply(id json.RawMessage, result any, err error) error {
+	resp := response{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{codeInternalError, err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = b
+	}
+	return framing.Write(s.w, resp)
+}
+
+func (s *Server) notify(method string, params any) error {
+	return framing.Write(s.w, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) ignore(json.RawMessage) (any, error) {
+	return nil, nil
+}
+
+// The types and modifiers of semantic tokens, indexed by the numbers sent to
+// the client.
+var (
+	tokenTypes     = []string{"keyword", "variable", "function", "parameter", "property", "string", "number", "operator", "comment"}
+	tokenModifiers = []string{"declaration", "defaultLibrary"}
+)
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p initializeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	// runes match Lox columns, so use them if the client can
+	encoding := "utf-16"
+	if slices.Contains(p.Capabilities.General.PositionEncodings, "utf-32") {
+		encoding = "utf-32"
+		s.utf16 = false
+	}
+	s.initialized = true
+	return map[string]any{
+		"capabilities": map[string]any{
+			"positionEncoding": encoding,
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    2, // incremental
+			},
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"documentSymbolProvider": true,
+			"semanticTokensProvider": map[string]any{
+				"legend": map[string]any{"tokenTypes": tokenTypes, "tokenModifiers": tokenModifiers},
+				"full":   true,
+			},
+		},
+		"serverInfo": map[string]any{"name": "lox"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (any, error) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d := newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text, s.utf16)
+	s.docs[d.uri] = d
+	return nil, s.publish(d)
+}
+
+func (s *Server) didChange(params json.RawMessage) (any, error) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range p.ContentChanges {
+		d.apply(c)
+	}
+	d.version = p.TextDocument.Version
+	return nil, s.publish(d)
+}
+
+func (s *Server) didClose(params json.RawMessage) (any, error) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []diagnostic{}})
+}
+
+// publish analyzes d and sends its diagnostics.
+func (s *Server) publish(d *document) error {
+	d.analyze(s.builtins)
+	version := d.version
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: d.uri, Version: &version, Diagnostics: d.diagnostics})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("document not open: %v", uri)}
+	}
+	return d, nil
+}
+
+// symbolAt returns the document and symbol at the position in params.
+func (s *Server) symbolAt(p positionParams) (*document, *lox.Symbol, lox.Token, error) {
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, lox.Token{}, err
+	}
+	sym, tok := d.symbolAt(p.Position)
+	return d, sym, tok, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	var p positionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, sym, tok, err := s.symbolAt(p)
+	if err != nil || sym == nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("```lox\n")
+	switch sym.Kind {
+	case lox.SymbolFunction:
+		fmt.Fprintf(&sb, "fun %v(%v)", sym.Name, strings.Join(sym.Params, ", "))
+	case lox.SymbolVariable:
+		fmt.Fprintf(&sb, "var %v", sym.Name)
+	default:
+		sb.WriteString(sym.Name)
+	}
+	sb.WriteString("\n```\n\n")
+	switch {
+	case sym.Kind == lox.SymbolBuiltin:
+		sb.WriteString("builtin defined by the interpreter")
+	case sym.Function != nil:
+		fmt.Fprintf(&sb, "%v of %v, declared on line %v", sym.Kind, sym.Function.Name, sym.Pos.Line)
+	default:
+		global := "local "
+		if sym.Global {
+			global = "global "
+		}
+		fmt.Fprintf(&sb, "%v%v declared on line %v", global, sym.Kind, sym.Pos.Line)
+	}
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: sb.String()},
+		Range:    d.tokenRange(tok),
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	var p positionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, sym, _, err := s.symbolAt(p)
+	if err != nil || sym == nil || sym.Kind == lox.SymbolBuiltin {
+		return nil, err
+	}
+	return location{URI: d.uri, Range: d.nameRange(sym.Pos, sym.Name)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (any, error) {
+	var p referenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, sym, _, err := s.symbolAt(p.positionParams)
+	if err != nil || sym == nil {
+		return nil, err
+	}
+	locations := []location{}
+	if p.Context.IncludeDeclaration && sym.Kind != lox.SymbolBuiltin {
+		locations = append(locations, location{URI: d.uri, Range: d.nameRange(sym.Pos, sym.Name)})
+	}
+	for _, ref := range sym.Refs {
+		locations = append(locations, location{URI: d.uri, Range: d.nameRange(ref, sym.Name)})
+	}
+	return locations, nil
+}
+
+// documentSymbol lists the functions and variables of a document, with those
+// a function declares as its children.
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []*documentSymbol{}
+	if d.res == nil {
+		return symbols, nil
+	}
+	functions := make(map[*lox.Symbol]*documentSymbol)
+	for _, sym := range d.res.Symbols {
+		if sym.Kind != lox.SymbolFunction && sym.Kind != lox.SymbolVariable {
+			continue
+		}
+		ds := &documentSymbol{
+			Name:           sym.Name,
+			Kind:           symbolKindVariable,
+			SelectionRange: d.nameRange(sym.Pos, sym.Name),
+		}
+		ds.Range = ds.SelectionRange
+		if end := d.statementEnd(sym.Decl); end.Line > 0 {
+			ds.Range = rng{d.position(sym.Decl.Line, sym.Decl.Column), d.tokenRange(end).End}
+		}
+		if sym.Kind == lox.SymbolFunction {
+			ds.Kind = symbolKindFunction
+			ds.Detail = "(" + strings.Join(sym.Params, ", ") + ")"
+			functions[sym] = ds
+		}
+		if parent, ok := functions[sym.Function]; ok {
+			parent.Children = append(parent.Children, ds)
+		} else {
+			symbols = append(symbols, ds)
+		}
+	}
+	return symbols, nil
+}
+
+// semanticTokens classifies the document's tokens, using the resolution to
+// tell functions, parameters and variables apart.
+func (s *Server) semanticTokens(params json.RawMessage) (any, error) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	keywords := make(map[lox.TokenType]bool)
+	for _, t := range lox.Keywords {
+		keywords[t] = true
+	}
+	data := []int{}
+	var line, char int
+	for i, tok := range d.tokens {
+		typ, modifiers := -1, 0
+		switch {
+		case keywords[tok.Type]:
+			typ = 0
+		case tok.Type == lox.TokenIdentifier:
+			typ = 1
+			if i > 0 && d.tokens[i-1].Type == lox.TokenDot {
+				typ = 4
+			}
+			if d.res == nil {
+				break
+			}
+			if sym := d.res.SymbolAt(tok.Position()); sym != nil {
+				switch sym.Kind {
+				case lox.SymbolFunction:
+					typ = 2
+				case lox.SymbolParameter:
+					typ = 3
+				case lox.SymbolBuiltin:
+					typ, modifiers = 2, 1<<1
+				}
+				if sym.Pos == tok.Position() {
+					modifiers |= 1 << 0
+				}
+			}
+		case tok.Type == lox.TokenString:
+			typ = 5
+		case tok.Type == lox.TokenNumber:
+			typ = 6
+		case tok.Type == lox.TokenComment:
+			typ = 8
+		case isOperator(tok.Type):
+			typ = 7
+		}
+		if typ < 0 {
+			continue
+		}
+		// tokens may not span lines, so split multi-line strings
+		for j, text := range strings.Split(tok.Lexeme, "\n") {
+			start := d.position(tok.Line+j, 1)
+			if j == 0 {
+				start = d.position(tok.Line, tok.Column)
+			}
+			if text == "" {
+				continue
+			}
+			if start.Line != line {
+				char = 0
+			}
+			data = append(data, start.Line-line, start.Character-char, d.width(text), typ, modifiers)
+			line, char = start.Line, start.Character
+		}
+	}
+	return semanticTokens{Data: data}, nil
+}
+
+func isOperator(t lox.TokenType) bool {
+	switch t {
+	case lox.TokenPlus, lox.TokenMinus, lox.TokenStar, lox.TokenSlash, lox.TokenBang,
+		lox.TokenEqual, lox.TokenEqualEqual, lox.TokenBangEqual,
+		lox.TokenLess, lox.TokenLessEqual, lox.TokenGreater, lox.TokenGreaterEqual:
+		return true
+	}
+	return false
+}
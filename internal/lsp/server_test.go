@@ -0,0 +1,215 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/framing"
+	"github.com/codecrafters-io/interpreter-starter-go/internal/lsp"
+)
+
+const uri = "file:///test.lox"
+
+// source has total on line 3 and add's parameters on line 0, counting from 0
+// as the protocol does.
+const source = `fun add(a, b) {
+  return a + b;
+}
+var total = add(1, 2);
+print total;
+`
+
+// A step sends one message to the server. Requests expect a response with the
+// result want; the notifications that change a document expect the
+// diagnostics published for it. Only the members present in want are
+// compared.
+type step struct {
+	name    string
+	method  string
+	request bool
+	params  string
+	want    string
+}
+
+func TestSession(t *testing.T) {
+	steps := []step{
+		{
+			name: "initialize", method: "initialize", request: true,
+			params: `{"capabilities":{"general":{"positionEncodings":["utf-32"]}}}`,
+			want:   `{"capabilities":{"positionEncoding":"utf-32","textDocumentSync":{"openClose":true,"change":2},"hoverProvider":true}}`,
+		},
+		{name: "initialized", method: "initialized", params: `{}`},
+		{
+			name: "open", method: "textDocument/didOpen",
+			params: fmt.Sprintf(`{"textDocument":{"uri":%q,"languageId":"lox","version":1,"text":%q}}`, uri, source),
+			want:   fmt.Sprintf(`{"uri":%q,"version":1,"diagnostics":[]}`, uri),
+		},
+		{
+			name: "hover on a global", method: "textDocument/hover", request: true,
+			params: position(4, 7),
+			want:   `{"contents":{"kind":"markdown","value":"` + "```lox\\nvar total\\n```\\n\\nglobal variable declared on line 4" + `"},"range":{"start":{"line":4,"character":6},"end":{"line":4,"character":11}}}`,
+		},
+		{
+			name: "hover on a parameter", method: "textDocument/hover", request: true,
+			params: position(1, 9),
+			want:   `{"contents":{"value":"` + "```lox\\na\\n```\\n\\nparameter of add, declared on line 1" + `"}}`,
+		},
+		{
+			name: "hover on nothing", method: "textDocument/hover", request: true,
+			params: position(2, 0),
+			want:   `null`,
+		},
+		{
+			name: "definition of a call", method: "textDocument/definition", request: true,
+			params: position(3, 13),
+			want:   fmt.Sprintf(`{"uri":%q,"range":{"start":{"line":0,"character":4},"end":{"line":0,"character":7}}}`, uri),
+		},
+		{
+			name: "references with the declaration", method: "textDocument/references", request: true,
+			params: fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":1,"character":13},"context":{"includeDeclaration":true}}`, uri),
+			want: fmt.Sprintf(`[
+				{"uri":%q,"range":{"start":{"line":0,"character":11},"end":{"line":0,"character":12}}},
+				{"uri":%[1]q,"range":{"start":{"line":1,"character":13},"end":{"line":1,"character":14}}}
+			]`, uri),
+		},
+		{
+			name: "references without the declaration", method: "textDocument/references", request: true,
+			params: fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":3,"character":5},"context":{"includeDeclaration":false}}`, uri),
+			want:   fmt.Sprintf(`[{"uri":%q,"range":{"start":{"line":4,"character":6},"end":{"line":4,"character":11}}}]`, uri),
+		},
+		{
+			name: "change introduces an error", method: "textDocument/didChange",
+			params: change(2, 1, 13, 1, 14, "c"),
+			want: fmt.Sprintf(`{"uri":%q,"version":2,"diagnostics":[
+				{"range":{"start":{"line":1,"character":13},"end":{"line":1,"character":14}},"severity":1,"message":"Undefined variable 'c'."}
+			]}`, uri),
+		},
+		{
+			name: "change inserts a declaration", method: "textDocument/didChange",
+			params: change(3, 1, 2, 1, 2, "var c = b; "),
+			want:   fmt.Sprintf(`{"uri":%q,"version":3,"diagnostics":[]}`, uri),
+		},
+		{
+			name: "positions follow the change", method: "textDocument/definition", request: true,
+			params: position(1, 24),
+			want:   fmt.Sprintf(`{"uri":%q,"range":{"start":{"line":1,"character":6},"end":{"line":1,"character":7}}}`, uri),
+		},
+		{
+			name: "unknown request", method: "textDocument/rename", request: true,
+			params: position(0, 4),
+			want:   `{"code":-32601}`,
+		},
+		{name: "shutdown", method: "shutdown", request: true, want: `null`},
+	}
+
+	toServer, client := io.Pipe()
+	fromServer, server := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- lsp.NewServer(toServer, server).Run()
+		server.Close()
+	}()
+	w, r := bufio.NewWriter(client), bufio.NewReader(fromServer)
+
+	for id, s := range steps {
+		msg := map[string]any{"jsonrpc": "2.0", "method": s.method}
+		if s.params != "" {
+			msg["params"] = json.RawMessage(s.params)
+		}
+		if s.request {
+			msg["id"] = id
+		}
+		if err := framing.Write(w, msg); err != nil {
+			t.Fatalf("%v: write: %v", s.name, err)
+		}
+		if s.want == "" {
+			continue
+		}
+		data, err := framing.Read(r)
+		if err != nil {
+			t.Fatalf("%v: read: %v", s.name, err)
+		}
+		var got struct {
+			ID     *int
+			Method string
+			Params json.RawMessage
+			Result json.RawMessage
+			Error  json.RawMessage
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%v: %v", s.name, err)
+		}
+		body := got.Params
+		switch {
+		case !s.request && got.Method != "textDocument/publishDiagnostics":
+			t.Fatalf("%v: got %s, want diagnostics", s.name, data)
+		case s.request && (got.ID == nil || *got.ID != id):
+			t.Fatalf("%v: got %s, want the response to request %v", s.name, data, id)
+		case got.Error != nil:
+			body = got.Error
+		case s.request:
+			body = got.Result
+		}
+		if body == nil {
+			body = json.RawMessage("null")
+		}
+		var gotValue, wantValue any
+		json.Unmarshal(body, &gotValue)
+		if err := json.Unmarshal([]byte(s.want), &wantValue); err != nil {
+			t.Fatalf("%v: bad want: %v", s.name, err)
+		}
+		if !contains(gotValue, wantValue) {
+			t.Errorf("%v:\ngot  %s\nwant %s", s.name, body, s.want)
+		}
+	}
+
+	framing.Write(w, map[string]any{"jsonrpc": "2.0", "method": "exit"})
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+}
+
+func position(line, character int) string {
+	return fmt.Sprintf(`{"textDocument":{"uri":%q},"position":{"line":%v,"character":%v}}`, uri, line, character)
+}
+
+// change replaces a range of the document with text.
+func change(version, startLine, startChar, endLine, endChar int, text string) string {
+	return fmt.Sprintf(`{"textDocument":{"uri":%q,"version":%v},"contentChanges":[
+		{"range":{"start":{"line":%v,"character":%v},"end":{"line":%v,"character":%v}},"text":%q}
+	]}`, uri, version, startLine, startChar, endLine, endChar, text)
+}
+
+// contains reports whether got has every object member of want, comparing
+// everything else exactly.
+func contains(got, want any) bool {
+	switch want := want.(type) {
+	case map[string]any:
+		got, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range want {
+			if !contains(got[k], v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		got, ok := got.([]any)
+		if !ok || len(got) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !contains(got[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(got, want)
+}
@@ -0,0 +1,192 @@
+package profile
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// Field numbers of the messages in pprof's profile.proto.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileMapping           = 3
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	mappingID             = 1
+	mappingFilename       = 5
+	mappingHasFunctions   = 7
+	mappingHasFilenames   = 8
+	mappingHasLineNumbers = 9
+
+	locationID        = 1
+	locationMappingID = 2
+	locationLine      = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WritePprof writes the profile gzipped in pprof's protocol buffer format. Each
+// sample holds the number of statements executed and the nanoseconds spent
+// with its stack.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strs := &stringTable{index: map[string]int64{"": 0}, strings: []string{""}}
+	var b protoBuffer
+	for _, t := range [][2]string{{"hits", "count"}, {"time", "nanoseconds"}} {
+		b.message(profileSampleType, func(b *protoBuffer) {
+			b.int64(valueTypeType, strs.add(t[0]))
+			b.int64(valueTypeUnit, strs.add(t[1]))
+		})
+	}
+	for _, s := range p.order {
+		b.message(profileSample, func(b *protoBuffer) {
+			ids := make([]uint64, len(s.locs))
+			for i, loc := range s.locs {
+				ids[i] = loc.id
+			}
+			b.packed(sampleLocationID, ids)
+			b.packed(sampleValue, []uint64{uint64(s.hits), uint64(s.nanos)})
+		})
+	}
+	// one mapping for the script tells pprof the locations need no
+	// symbolizing
+	b.message(profileMapping, func(b *protoBuffer) {
+		b.uint64(mappingID, 1)
+		b.int64(mappingFilename, strs.add(p.file))
+		b.uint64(mappingHasFunctions, 1)
+		b.uint64(mappingHasFilenames, 1)
+		b.uint64(mappingHasLineNumbers, 1)
+	})
+	locs := make([]*location, len(p.locations))
+	for _, loc := range p.locations {
+		locs[loc.id-1] = loc
+	}
+	for _, loc := range locs {
+		b.message(profileLocation, func(b *protoBuffer) {
+			b.uint64(locationID, loc.id)
+			b.uint64(locationMappingID, 1)
+			b.message(locationLine, func(b *protoBuffer) {
+				b.uint64(lineFunctionID, loc.fn.id)
+				b.int64(lineLine, int64(loc.line))
+			})
+		})
+	}
+	fns := make([]*function, len(p.functions))
+	for _, fn := range p.functions {
+		fns[fn.id-1] = fn
+	}
+	for _, fn := range fns {
+		b.message(profileFunction, func(b *protoBuffer) {
+			b.uint64(functionID, fn.id)
+			b.int64(functionName, strs.add(fn.name))
+			b.int64(functionSystemName, strs.add(fn.name))
+			b.int64(functionFilename, strs.add(p.file))
+			b.int64(functionStartLine, int64(fn.line))
+		})
+	}
+	b.int64(profileTimeNanos, p.start.UnixNano())
+	b.int64(profileDurationNanos, int64(p.duration()))
+	b.message(profilePeriodType, func(b *protoBuffer) {
+		b.int64(valueTypeType, strs.add("time"))
+		b.int64(valueTypeUnit, strs.add("nanoseconds"))
+	})
+	b.int64(profilePeriod, 1)
+	b.int64(profileDefaultSampleType, strs.add("time"))
+	// the string table goes last, once every string has been added
+	for _, s := range strs.strings {
+		b.string(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type stringTable struct {
+	index   map[string]int64
+	strings []string
+}
+
+func (t *stringTable) add(s string) int64 {
+	i, ok := t.index[s]
+	if !ok {
+		i = int64(len(t.strings))
+		t.index[s] = i
+		t.strings = append(t.strings, s)
+	}
+	return i
+}
+
+// protoBuffer encodes protocol buffer fields. Zero numbers are left out, as
+// they are the default.
+type protoBuffer struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) key(field, wire int) {
+	b.buf = binary.AppendUvarint(b.buf, uint64(field)<<3|uint64(wire))
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.buf = binary.AppendUvarint(b.buf, x)
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) bytes(field int, p []byte) {
+	b.key(field, wireBytes)
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(p)))
+	b.buf = append(b.buf, p...)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protoBuffer) packed(field int, xs []uint64) {
+	var inner []byte
+	for _, x := range xs {
+		inner = binary.AppendUvarint(inner, x)
+	}
+	b.bytes(field, inner)
+}
+
+// message encodes the fields fn writes as an embedded message.
+func (b *protoBuffer) message(field int, fn func(b *protoBuffer)) {
+	var inner protoBuffer
+	fn(&inner)
+	b.bytes(field, inner.buf)
+}
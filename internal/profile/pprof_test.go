@@ -0,0 +1,208 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/internal/profile"
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// field is a decoded protocol buffer field, holding a number for varints and
+// the contents for length delimited fields.
+type field struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeFields splits a protocol buffer message into its fields.
+func decodeFields(t *testing.T, b []byte) []field {
+	t.Helper()
+	var fields []field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("bad field key")
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("bad varint in field %v", f.num)
+			}
+			b = b[n:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				t.Fatalf("bad length in field %v", f.num)
+			}
+			f.bytes, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %v in field %v", key&7, f.num)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// varint returns the value of field num in fields, or 0 if it is absent.
+func varint(fields []field, num int) uint64 {
+	for _, f := range fields {
+		if f.num == num {
+			return f.varint
+		}
+	}
+	return 0
+}
+
+// packed decodes a packed repeated varint field.
+func packed(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+	var xs []uint64
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("bad packed varint")
+		}
+		xs, b = append(xs, x), b[n:]
+	}
+	return xs
+}
+
+func TestWritePprof(t *testing.T) {
+	const source = `fun f(n) {
+  return n + 1;
+}
+var a = f(1);
+print a + f(2);
+`
+	prof := profile.New("script.lox")
+	interp := lox.New(lox.WithObserver(prof), lox.WithStdout(io.Discard))
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	prof.Stop()
+	var buf bytes.Buffer
+	if err := prof.WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+
+	// field numbers from pprof's profile.proto
+	fields := decodeFields(t, data)
+	var strs []string
+	for _, f := range fields {
+		if f.num == 6 {
+			strs = append(strs, string(f.bytes))
+		}
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table = %q, want it to start with the empty string", strs)
+	}
+	str := func(i uint64) string {
+		if i >= uint64(len(strs)) {
+			t.Fatalf("string index %v out of range", i)
+		}
+		return strs[i]
+	}
+
+	var sampleTypes []string
+	functions := make(map[uint64]string)
+	locations := make(map[uint64]string)
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			vt := decodeFields(t, f.bytes)
+			sampleTypes = append(sampleTypes, str(varint(vt, 1))+"/"+str(varint(vt, 2)))
+		case 5:
+			fn := decodeFields(t, f.bytes)
+			if file := str(varint(fn, 4)); file != "script.lox" {
+				t.Errorf("function %v is in file %q, want script.lox", str(varint(fn, 2)), file)
+			}
+			functions[varint(fn, 1)] = fmt.Sprintf("%v@%v", str(varint(fn, 2)), varint(fn, 5))
+		}
+	}
+	if want := []string{"hits/count", "time/nanoseconds"}; !reflect.DeepEqual(sampleTypes, want) {
+		t.Errorf("sample types = %q, want %q", sampleTypes, want)
+	}
+	if want := map[uint64]string{1: "script@0", 2: "f@1"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	for _, f := range fields {
+		if f.num == 4 {
+			loc := decodeFields(t, f.bytes)
+			var line []field
+			for _, lf := range loc {
+				if lf.num == 4 {
+					line = decodeFields(t, lf.bytes)
+				}
+			}
+			fn, ok := functions[varint(line, 1)]
+			if !ok {
+				t.Fatalf("location %v refers to unknown function %v", varint(loc, 1), varint(line, 1))
+			}
+			locations[varint(loc, 1)] = fmt.Sprintf("%v:%v", strings.Split(fn, "@")[0], varint(line, 2))
+		}
+	}
+
+	// hits counts the statements each stack executed
+	hits := make(map[string]uint64)
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+		sample := decodeFields(t, f.bytes)
+		var stack []string
+		var values []uint64
+		for _, sf := range sample {
+			switch sf.num {
+			case 1:
+				for _, id := range packed(t, sf.bytes) {
+					loc, ok := locations[id]
+					if !ok {
+						t.Fatalf("sample refers to unknown location %v", id)
+					}
+					stack = append(stack, loc)
+				}
+			case 2:
+				values = packed(t, sf.bytes)
+			}
+		}
+		if len(values) != 2 {
+			t.Fatalf("sample %v has values %v, want hits and time", stack, values)
+		}
+		if values[0] > 0 {
+			hits[strings.Join(stack, " ")] += values[0]
+		}
+	}
+	want := map[string]uint64{
+		"script:1":     1,
+		"script:4":     1,
+		"f:2 script:4": 1,
+		"script:5":     1,
+		"f:2 script:5": 1,
+	}
+	if !reflect.DeepEqual(hits, want) {
+		t.Errorf("hits = %v, want %v", hits, want)
+	}
+	if str(varint(fields, 14)) != "time" {
+		t.Errorf("default sample type = %q, want time", str(varint(fields, 14)))
+	}
+}
@@ -0,0 +1,272 @@
+// Package profile measures where a Lox script spends its time. A Profiler
+// observes the evaluator and charges the time between one event and the next
+// to the call stack and line that were running, counting the statements each
+// line executes and the calls each function receives. It writes what it found
+// as a pprof profile or as a plain text summary.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// function is a Lox function, a native, or the script's top level.
+type function struct {
+	id    uint64
+	name  string
+	line  int // line of the declaration, 0 for natives and the top level
+	calls int64
+}
+
+// location is a line within a function.
+type location struct {
+	id   uint64
+	fn   *function
+	line int
+}
+
+type frame struct {
+	fn   *function
+	line int
+}
+
+// sample is the time spent and statements executed with one call stack.
+type sample struct {
+	locs  []*location // innermost first
+	hits  int64
+	nanos int64
+}
+
+type funcKey struct {
+	name string
+	line int
+}
+
+type locKey struct {
+	fn   *function
+	line int
+}
+
+// Profiler records a profile of one run of a script. It implements
+// lox.Observer.
+type Profiler struct {
+	lox.NopObserver
+	file       string
+	start      time.Time
+	last       time.Time
+	stack      []frame
+	functions  map[funcKey]*function
+	locations  map[locKey]*location
+	samples    map[string]*sample
+	order      []*sample // samples in the order they were first seen
+	statements int64
+	key        []byte
+}
+
+// New returns a profiler for a script read from file.
+func New(file string) *Profiler {
+	p := &Profiler{
+		file:      file,
+		functions: make(map[funcKey]*function),
+		locations: make(map[locKey]*location),
+		samples:   make(map[string]*sample),
+	}
+	p.stack = []frame{{fn: p.function("script", 0)}}
+	p.stack[0].fn.calls = 1
+	return p
+}
+
+func (p *Profiler) function(name string, line int) *function {
+	k := funcKey{name, line}
+	fn, ok := p.functions[k]
+	if !ok {
+		fn = &function{id: uint64(len(p.functions) + 1), name: name, line: line}
+		p.functions[k] = fn
+	}
+	return fn
+}
+
+func (p *Profiler) location(fn *function, line int) *location {
+	k := locKey{fn, line}
+	loc, ok := p.locations[k]
+	if !ok {
+		loc = &location{id: uint64(len(p.locations) + 1), fn: fn, line: line}
+		p.locations[k] = loc
+	}
+	return loc
+}
+
+// current returns the sample for the stack as it is now.
+func (p *Profiler) current() *sample {
+	p.key = p.key[:0]
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		f := p.stack[i]
+		p.key = strconv.AppendUint(p.key, p.location(f.fn, f.line).id, 10)
+		p.key = append(p.key, ',')
+	}
+	s, ok := p.samples[string(p.key)]
+	if !ok {
+		s = &sample{}
+		for i := len(p.stack) - 1; i >= 0; i-- {
+			s.locs = append(s.locs, p.location(p.stack[i].fn, p.stack[i].line))
+		}
+		p.samples[string(p.key)] = s
+		p.order = append(p.order, s)
+	}
+	return s
+}
+
+// tick charges the time since the last event to the current stack.
+func (p *Profiler) tick() {
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start, p.last = now, now
+		return
+	}
+	p.current().nanos += int64(now.Sub(p.last))
+	p.last = now
+}
+
+func (p *Profiler) StmtEnter(e *lox.Evaluator, s lox.Statement) {
+	if _, ok := s.(lox.BlockStmt); ok {
+		return // the statements inside are counted instead
+	}
+	p.tick()
+	p.stack[len(p.stack)-1].line = s.Position().Line
+	p.current().hits++
+	p.statements++
+}
+
+func (p *Profiler) CallEnter(e *lox.Evaluator, fn lox.Callable, args []lox.Value, line int) {
+	p.tick()
+	var f *function
+	switch fn := fn.(type) {
+	case *lox.LoxFunction:
+		f = p.function(fn.Name(), fn.Line())
+	case interface{ Name() string }:
+		f = p.function(fn.Name(), 0)
+	default:
+		f = p.function(fmt.Sprint(fn), 0)
+	}
+	f.calls++
+	p.stack = append(p.stack, frame{fn: f, line: f.line})
+}
+
+func (p *Profiler) CallExit(e *lox.Evaluator, fn lox.Callable, result lox.Value, err error) {
+	p.tick()
+	p.stack = p.stack[:len(p.stack)-1]
+}
+
+// Stop charges the time since the last event. Call it once the script has
+// finished.
+func (p *Profiler) Stop() {
+	p.tick()
+}
+
+func (p *Profiler) duration() time.Duration {
+	return p.last.Sub(p.start)
+}
+
+// row is a line of the summary.
+type row struct {
+	name       string
+	flat, cum  int64
+	count      int64
+	firstOrder int
+}
+
+// WriteSummary writes the n functions and n lines that took the most time,
+// with the time spent in them alone and including what they called.
+func (p *Profiler) WriteSummary(w io.Writer, n int) error {
+	total := int64(p.duration())
+	funcs := make(map[*function]*row)
+	lines := make(map[*location]*row)
+	for i, s := range p.order {
+		seenFn := make(map[*function]bool)
+		seenLoc := make(map[*location]bool)
+		for depth, loc := range s.locs {
+			fr, ok := funcs[loc.fn]
+			if !ok {
+				fr = &row{name: p.funcName(loc.fn), count: loc.fn.calls, firstOrder: i}
+				funcs[loc.fn] = fr
+			}
+			lr, ok := lines[loc]
+			if !ok {
+				lr = &row{name: fmt.Sprintf("%v:%v in %v", p.file, loc.line, loc.fn.name), firstOrder: i}
+				lines[loc] = lr
+			}
+			if depth == 0 {
+				fr.flat += s.nanos
+				lr.flat += s.nanos
+				lr.count += s.hits
+			}
+			if !seenFn[loc.fn] {
+				seenFn[loc.fn] = true
+				fr.cum += s.nanos
+			}
+			if !seenLoc[loc] {
+				seenLoc[loc] = true
+				lr.cum += s.nanos
+			}
+		}
+	}
+	fmt.Fprintf(w, "Total: %.2fms, %v statements\n\n", millis(total), p.statements)
+	if err := writeRows(w, funcs, n, total, "calls", "function"); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	return writeRows(w, lines, n, total, "hits", "line")
+}
+
+func (p *Profiler) funcName(fn *function) string {
+	if fn.line == 0 {
+		return fn.name
+	}
+	return fmt.Sprintf("%v (%v:%v)", fn.name, p.file, fn.line)
+}
+
+func writeRows[K comparable](w io.Writer, rows map[K]*row, n int, total int64, count, name string) error {
+	sorted := make([]*row, 0, len(rows))
+	for _, r := range rows {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].flat != sorted[j].flat {
+			return sorted[i].flat > sorted[j].flat
+		}
+		if sorted[i].cum != sorted[j].cum {
+			return sorted[i].cum > sorted[j].cum
+		}
+		return sorted[i].firstOrder < sorted[j].firstOrder
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	fmt.Fprintf(w, "%12v %7v %12v %7v %9v  %v\n", "flat", "flat%", "cum", "cum%", count, name)
+	for _, r := range sorted {
+		_, err := fmt.Fprintf(w, "%10.2fms %6.2f%% %10.2fms %6.2f%% %9v  %v\n",
+			millis(r.flat), percent(r.flat, total),
+			millis(r.cum), percent(r.cum, total),
+			r.count, r.name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func millis(nanos int64) float64 {
+	return float64(nanos) / float64(time.Millisecond)
+}
+
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
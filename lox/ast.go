@@ -0,0 +1,387 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a position in the source. Lines and columns are counted from 1, and
+// columns in runes.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+// Position returns p. Every node embeds a Pos holding the position of its
+// first token.
+func (p Pos) Position() Pos {
+	return p
+}
+
+type Statement interface {
+	fmt.Stringer
+	Position() Pos
+	stmt()
+	accept(Visitor) error
+}
+
+type BlockStmt struct {
+	Pos
+	Body []Statement
+}
+
+func (v BlockStmt) stmt() {}
+func (b BlockStmt) String() string {
+	sb := &strings.Builder{}
+	for _, s := range b.Body {
+		fmt.Fprintln(sb, s.String())
+	}
+	return sb.String()
+}
+
+func (s BlockStmt) accept(v Visitor) error {
+	return v.VisitBlockStmt(s)
+}
+
+type VarDeclStmt struct {
+	Pos
+	Name       Token
+	Expression Expression
+}
+
+func (v VarDeclStmt) stmt() {}
+func (v VarDeclStmt) String() string {
+	return fmt.Sprintf("(= %v %v)", v.Name.Lit(), v.Expression.String())
+}
+func (s VarDeclStmt) accept(v Visitor) error {
+	return v.VisitVarDeclStmt(s)
+}
+
+type PrintStmt struct {
+	Pos
+	Expression Expression
+}
+
+func (p PrintStmt) stmt() {}
+func (p PrintStmt) String() string {
+	return fmt.Sprintf("(print %v)", p.Expression.String())
+}
+func (p PrintStmt) accept(v Visitor) error {
+	return v.VisitPrintStmt(p)
+}
+
+type ExpressionStmt struct {
+	Pos
+	Expression Expression
+}
+
+func (e ExpressionStmt) stmt() {}
+func (e ExpressionStmt) String() string {
+	return e.Expression.String()
+}
+func (e ExpressionStmt) accept(v Visitor) error {
+	return v.VisitExpressionStmt(e)
+}
+
+type ThrowStmt struct {
+	Pos
+	Expression Expression
+}
+
+func (t ThrowStmt) stmt() {}
+func (t ThrowStmt) String() string {
+	return fmt.Sprintf("(throw %v)", t.Expression)
+}
+func (t ThrowStmt) accept(v Visitor) error {
+	return v.VisitThrowStmt(t)
+}
+
+type TryStmt struct {
+	Pos
+	Body      BlockStmt
+	CatchName Token
+	Catch     *BlockStmt
+	Finally   *BlockStmt
+}
+
+func (t TryStmt) stmt() {}
+func (t TryStmt) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "(try\n%v", t.Body)
+	if t.Catch != nil {
+		fmt.Fprintf(sb, "(catch %v\n%v)", t.CatchName.Lit(), t.Catch)
+	}
+	if t.Finally != nil {
+		fmt.Fprintf(sb, "(finally\n%v)", t.Finally)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+func (t TryStmt) accept(v Visitor) error {
+	return v.VisitTryStmt(t)
+}
+
+type FunDeclStmt struct {
+	Pos
+	Name   Token
+	Params []Token
+	Body   []Statement
+}
+
+func (f FunDeclStmt) stmt() {}
+func (f FunDeclStmt) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "(fun %v (", f.Name.Lit())
+	for i, param := range f.Params {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(param.Lit())
+	}
+	sb.WriteString(")\n")
+	for _, s := range f.Body {
+		fmt.Fprintln(sb, s.String())
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+func (f FunDeclStmt) accept(v Visitor) error {
+	return v.VisitFunDeclStmt(f)
+}
+
+type ReturnStmt struct {
+	Pos
+	Keyword Token
+	Value   Expression
+}
+
+func (r ReturnStmt) stmt() {}
+func (r ReturnStmt) String() string {
+	if r.Value == nil {
+		return "(return)"
+	}
+	return fmt.Sprintf("(return %v)", r.Value)
+}
+func (r ReturnStmt) accept(v Visitor) error {
+	return v.VisitReturnStmt(r)
+}
+
+type Expression interface {
+	fmt.Stringer
+	Position() Pos
+	expr()
+	accept(Visitor) (any, error)
+}
+
+// Number
+type NumberExpr struct {
+	Pos
+	Value float64
+}
+
+func (n NumberExpr) expr() {}
+func (n NumberExpr) String() string {
+	intVal := int64(n.Value)
+	if n.Value-float64(intVal) == 0 {
+		return fmt.Sprintf("%v.0", n.Value)
+	}
+	return fmt.Sprintf("%v", n.Value)
+}
+func (n NumberExpr) accept(v Visitor) (any, error) {
+	return v.VisitNumberExpr(n)
+}
+
+// String
+type StringExpr struct {
+	Pos
+	Value string
+}
+
+func (s StringExpr) expr() {}
+func (s StringExpr) String() string {
+	return s.Value
+}
+func (s StringExpr) accept(v Visitor) (any, error) {
+	return v.VisitStringExpr(s)
+}
+
+// Identifier
+type IdentifierExpr struct {
+	Pos
+	Value string
+}
+
+func (i IdentifierExpr) expr() {}
+func (i IdentifierExpr) String() string {
+	return i.Value
+}
+func (i IdentifierExpr) accept(v Visitor) (any, error) {
+	return v.VisitIdentifierExpr(i)
+}
+
+// Unary expression
+
+type UnaryExpr struct {
+	Pos
+	Op      Token
+	Operand Expression
+}
+
+func (u UnaryExpr) expr() {}
+func (u UnaryExpr) String() string {
+	return fmt.Sprintf("(%v %v)", u.Op.Lit(), u.Operand)
+}
+func (u UnaryExpr) accept(v Visitor) (any, error) {
+	return v.VisitUnaryExpr(u)
+}
+
+// Binary expression
+type BinaryExpr struct {
+	Pos
+	Left  Expression
+	Op    Token
+	Right Expression
+}
+
+func (b BinaryExpr) expr() {}
+func (b BinaryExpr) String() string {
+	return fmt.Sprintf("(%v %v %v)", b.Op.Lit(), b.Left, b.Right)
+}
+func (b BinaryExpr) accept(v Visitor) (any, error) {
+	return v.VisitBinaryExpr(b)
+}
+
+type AssignmentExpr struct {
+	Pos
+	Identifier Token
+	Value      Expression
+}
+
+func (b AssignmentExpr) expr() {}
+func (b AssignmentExpr) String() string {
+	return fmt.Sprintf("(= %v %v)", b.Identifier.Lit(), b.Value)
+}
+func (b AssignmentExpr) accept(v Visitor) (any, error) {
+	return v.VisitAssignmentExpr(b)
+}
+
+// Member access
+type GetExpr struct {
+	Pos
+	Object Expression
+	Name   Token
+}
+
+func (g GetExpr) expr() {}
+func (g GetExpr) String() string {
+	return fmt.Sprintf("(. %v %v)", g.Object, g.Name.Lit())
+}
+func (g GetExpr) accept(v Visitor) (any, error) {
+	return v.VisitGetExpr(g)
+}
+
+type SetExpr struct {
+	Pos
+	Object Expression
+	Name   Token
+	Value  Expression
+}
+
+func (s SetExpr) expr() {}
+func (s SetExpr) String() string {
+	return fmt.Sprintf("(= (. %v %v) %v)", s.Object, s.Name.Lit(), s.Value)
+}
+func (s SetExpr) accept(v Visitor) (any, error) {
+	return v.VisitSetExpr(s)
+}
+
+// Index expression
+type IndexExpr struct {
+	Pos
+	Object  Expression
+	Bracket Token
+	Index   Expression
+}
+
+func (i IndexExpr) expr() {}
+func (i IndexExpr) String() string {
+	return fmt.Sprintf("([] %v %v)", i.Object, i.Index)
+}
+func (i IndexExpr) accept(v Visitor) (any, error) {
+	return v.VisitIndexExpr(i)
+}
+
+type SetIndexExpr struct {
+	Pos
+	Object  Expression
+	Bracket Token
+	Index   Expression
+	Value   Expression
+}
+
+func (s SetIndexExpr) expr() {}
+func (s SetIndexExpr) String() string {
+	return fmt.Sprintf("(= ([] %v %v) %v)", s.Object, s.Index, s.Value)
+}
+func (s SetIndexExpr) accept(v Visitor) (any, error) {
+	return v.VisitSetIndexExpr(s)
+}
+
+type CallExpr struct {
+	Pos
+	Callee Expression
+	Paren  Token
+	Args   []Expression
+}
+
+func (c CallExpr) expr() {}
+func (c CallExpr) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "(call %v", c.Callee)
+	for _, arg := range c.Args {
+		fmt.Fprintf(sb, " %v", arg)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+func (c CallExpr) accept(v Visitor) (any, error) {
+	return v.VisitCallExpr(c)
+}
+
+type BoolExpr struct {
+	Pos
+	Value bool
+}
+
+func (b BoolExpr) expr() {}
+func (b BoolExpr) String() string {
+	return fmt.Sprintf("%v", b.Value)
+}
+func (b BoolExpr) accept(v Visitor) (any, error) {
+	return v.VisitBoolExpr(b)
+}
+
+type NilExpr struct {
+	Pos
+}
+
+func (n NilExpr) expr() {}
+func (n NilExpr) String() string {
+	return "nil"
+}
+func (n NilExpr) accept(v Visitor) (any, error) {
+	return v.VisitNilExpr(n)
+}
+
+type GroupExpr struct {
+	Pos
+	Expression Expression
+}
+
+func (g GroupExpr) expr() {}
+func (g GroupExpr) String() string {
+	return fmt.Sprintf("(group %v)", g.Expression)
+}
+func (g GroupExpr) accept(v Visitor) (any, error) {
+	return v.VisitGroupExpr(g)
+}
@@ -0,0 +1,78 @@
+package lox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// WriteDot writes the program's syntax tree to w as a Graphviz graph. Each
+// node is labelled with its type, position, operator and name tokens and
+// literal values, with an edge to each child labelled by the field holding it.
+func (p *Program) WriteDot(w io.Writer) error {
+	d := &dotWriter{w: bufio.NewWriter(w)}
+	fmt.Fprintln(d.w, "digraph ast {")
+	fmt.Fprintln(d.w, `	node [shape=box, fontname="monospace"];`)
+	d.node(reflect.ValueOf(p.block))
+	fmt.Fprintln(d.w, "}")
+	return d.w.Flush()
+}
+
+type dotWriter struct {
+	w     *bufio.Writer
+	nodes int
+}
+
+// node writes the node v and its children, returning v's id.
+func (d *dotWriter) node(v reflect.Value) int {
+	id := d.nodes
+	d.nodes++
+	pos := v.FieldByName("Pos").Interface().(Pos)
+	label := []string{fmt.Sprintf("%v %v:%v", v.Type().Name(), pos.Line, pos.Column)}
+	type edge struct {
+		to    int
+		label string
+	}
+	var edges []edge
+	for i := 0; i < v.NumField(); i++ {
+		field, name := v.Field(i), jsonFieldName(v.Type().Field(i).Name)
+		switch {
+		case field.Type() == posType:
+		case field.Type() == tokenType:
+			label = append(label, fmt.Sprintf("%v: %v", name, field.Interface().(Token).Literal))
+		case field.Type() == reflect.TypeOf([]Token(nil)):
+			lits := make([]string, field.Len())
+			for j := range lits {
+				lits[j] = field.Index(j).Interface().(Token).Literal
+			}
+			label = append(label, fmt.Sprintf("%v: %v", name, strings.Join(lits, ", ")))
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				edges = append(edges, edge{d.node(field.Index(j).Elem()), fmt.Sprintf("%v[%v]", name, j)})
+			}
+		case field.Kind() == reflect.Interface || field.Kind() == reflect.Pointer:
+			if !field.IsNil() {
+				edges = append(edges, edge{d.node(field.Elem()), name})
+			}
+		case field.Kind() == reflect.Struct:
+			edges = append(edges, edge{d.node(field), name})
+		case field.Kind() == reflect.String:
+			label = append(label, fmt.Sprintf("%v: \"%v\"", name, field.String()))
+		default:
+			label = append(label, fmt.Sprintf("%v: %v", name, field.Interface()))
+		}
+	}
+	fmt.Fprintf(d.w, "\tn%v [label=%v];\n", id, dotQuote(strings.Join(label, "\n")+"\n"))
+	for _, e := range edges {
+		fmt.Fprintf(d.w, "\tn%v -> n%v [label=%v];\n", id, e.to, dotQuote(e.label))
+	}
+	return id
+}
+
+// dotQuote returns s as a DOT string, ending each line with \l so that lines
+// are left justified.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\l`).Replace(s) + `"`
+}
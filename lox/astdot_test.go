@@ -0,0 +1,55 @@
+package lox_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestWriteDot(t *testing.T) {
+	prog, err := lox.Compile("fun f(a, b) { return a + \"x\\y\"; }\ntry { print f(1, 2); } catch (e) {}\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := prog.WriteDot(&buf); err != nil {
+		t.Fatalf("WriteDot: %v", err)
+	}
+	// children are written before their parents, and quotes and backslashes
+	// in labels are escaped
+	want := `digraph ast {
+	node [shape=box, fontname="monospace"];
+	n4 [label="IdentifierExpr 1:22\lvalue: \"a\"\l"];
+	n5 [label="StringExpr 1:26\lvalue: \"x\\y\"\l"];
+	n3 [label="BinaryExpr 1:22\lop: +\l"];
+	n3 -> n4 [label="left"];
+	n3 -> n5 [label="right"];
+	n2 [label="ReturnStmt 1:15\lkeyword: return\l"];
+	n2 -> n3 [label="value"];
+	n1 [label="FunDeclStmt 1:1\lname: f\lparams: a, b\l"];
+	n1 -> n2 [label="body[0]"];
+	n10 [label="IdentifierExpr 2:13\lvalue: \"f\"\l"];
+	n11 [label="NumberExpr 2:15\lvalue: 1\l"];
+	n12 [label="NumberExpr 2:18\lvalue: 2\l"];
+	n9 [label="CallExpr 2:13\lparen: (\l"];
+	n9 -> n10 [label="callee"];
+	n9 -> n11 [label="args[0]"];
+	n9 -> n12 [label="args[1]"];
+	n8 [label="PrintStmt 2:7\l"];
+	n8 -> n9 [label="expression"];
+	n7 [label="BlockStmt 2:5\l"];
+	n7 -> n8 [label="body[0]"];
+	n13 [label="BlockStmt 2:34\l"];
+	n6 [label="TryStmt 2:1\lcatchName: e\l"];
+	n6 -> n7 [label="body"];
+	n6 -> n13 [label="catch"];
+	n0 [label="BlockStmt 1:1\l"];
+	n0 -> n1 [label="body[0]"];
+	n0 -> n6 [label="body[1]"];
+}
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
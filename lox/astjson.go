@@ -0,0 +1,256 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The JSON form of a Program is its top level BlockStmt. Every node is an
+// object with a "type" naming its Go type, the "line" and "column" of its Pos
+// and one member per field, named after the field with a lower case first
+// letter. Tokens are objects with "type", "literal", "line" and "column"
+// members, where "type" is the TokenType's Name. Absent optional children,
+// such as a missing catch block, are null.
+
+var nodeTypes = make(map[string]reflect.Type)
+
+// optionalFields lists the interface fields that may hold no node.
+var optionalFields = map[string]bool{
+	"ReturnStmt.Value": true,
+}
+
+var (
+	tokenType = reflect.TypeOf(Token{})
+	posType   = reflect.TypeOf(Pos{})
+)
+
+func init() {
+	for _, node := range []any{
+		BlockStmt{}, VarDeclStmt{}, PrintStmt{}, ExpressionStmt{}, ThrowStmt{}, TryStmt{},
+		FunDeclStmt{}, ReturnStmt{}, NumberExpr{}, StringExpr{}, IdentifierExpr{}, UnaryExpr{},
+		BinaryExpr{}, AssignmentExpr{}, GetExpr{}, SetExpr{}, IndexExpr{}, SetIndexExpr{},
+		CallExpr{}, BoolExpr{}, NilExpr{}, GroupExpr{},
+	} {
+		t := reflect.TypeOf(node)
+		nodeTypes[t.Name()] = t
+	}
+}
+
+// NewProgram returns a Program that runs block, which tools may have built or
+// transformed instead of parsing it from source.
+func NewProgram(block BlockStmt) *Program {
+	return &Program{block: block}
+}
+
+// Block returns the program's top level statements.
+func (p *Program) Block() BlockStmt {
+	return p.block
+}
+
+// MarshalJSON encodes the program's syntax tree.
+func (p *Program) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeJSONValue(&buf, reflect.ValueOf(p.block)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a syntax tree written by MarshalJSON.
+func (p *Program) UnmarshalJSON(data []byte) error {
+	var block BlockStmt
+	if err := readJSONValue(data, reflect.ValueOf(&block).Elem(), "program", false); err != nil {
+		return fmt.Errorf("decode syntax tree: %w", err)
+	}
+	p.block = block
+	return nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, v reflect.Value) error {
+	switch {
+	case v.Type() == tokenType:
+		tok := v.Interface().(Token)
+		return writeJSONObject(buf, "type", tok.Type.Name(), "literal", tok.Literal, "line", tok.Line, "column", tok.Column)
+	case v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSONValue(buf, v.Elem())
+	case v.Kind() == reflect.Slice:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case v.Kind() == reflect.Struct:
+		return writeJSONNode(buf, v)
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func writeJSONNode(buf *bytes.Buffer, v reflect.Value) error {
+	pos := v.FieldByName("Pos").Interface().(Pos)
+	fmt.Fprintf(buf, `{"type":%q,"line":%d,"column":%d`, v.Type().Name(), pos.Line, pos.Column)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type == posType {
+			continue
+		}
+		fmt.Fprintf(buf, ",%q:", jsonFieldName(field.Name))
+		if err := writeJSONValue(buf, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// writeJSONObject writes an object with the given member names and values,
+// keeping them in the order given where a map would sort them.
+func writeJSONObject(buf *bytes.Buffer, members ...any) error {
+	buf.WriteByte('{')
+	for i := 0; i < len(members); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(members[i+1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%q:%s", members[i], b)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func readJSONValue(data []byte, v reflect.Value, path string, optional bool) error {
+	isNull := bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+	switch {
+	case v.Type() == tokenType:
+		var tok struct {
+			Type    string
+			Literal string
+			Line    int
+			Column  int
+		}
+		if err := json.Unmarshal(data, &tok); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		t, ok := TokenTypeByName(tok.Type)
+		if !ok {
+			return fmt.Errorf("%v: unknown token type %q", path, tok.Type)
+		}
+		v.Set(reflect.ValueOf(Token{Type: t, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}))
+		return nil
+	case v.Kind() == reflect.Interface:
+		if isNull {
+			if !optional {
+				return fmt.Errorf("%v: missing node", path)
+			}
+			return nil
+		}
+		var header struct{ Type string }
+		if err := json.Unmarshal(data, &header); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		t, ok := nodeTypes[header.Type]
+		if !ok {
+			return fmt.Errorf("%v: unknown node type %q", path, header.Type)
+		}
+		if !t.Implements(v.Type()) {
+			return fmt.Errorf("%v: expected %v, got %v", path, v.Type().Name(), t.Name())
+		}
+		node := reflect.New(t).Elem()
+		if err := readJSONNode(data, node, path); err != nil {
+			return err
+		}
+		v.Set(node)
+		return nil
+	case v.Kind() == reflect.Pointer:
+		if isNull {
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := readJSONValue(data, elem.Elem(), path, false); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case v.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := readJSONValue(item, slice.Index(i), fmt.Sprintf("%v[%v]", path, i), false); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case v.Kind() == reflect.Struct:
+		return readJSONNode(data, v, path)
+	}
+	if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	return nil
+}
+
+func readJSONNode(data []byte, v reflect.Value, path string) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return fmt.Errorf("%v: %w", path, err)
+	}
+	var typeName string
+	json.Unmarshal(members["type"], &typeName)
+	if typeName != v.Type().Name() {
+		return fmt.Errorf("%v: expected %v, got %q", path, v.Type().Name(), typeName)
+	}
+	var pos Pos
+	json.Unmarshal(members["line"], &pos.Line)
+	json.Unmarshal(members["column"], &pos.Column)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Type == posType {
+			v.Field(i).Set(reflect.ValueOf(pos))
+			continue
+		}
+		name := jsonFieldName(field.Name)
+		raw, ok := members[name]
+		switch {
+		case ok:
+		case field.Type.Kind() == reflect.Slice:
+			raw = json.RawMessage("[]")
+		default:
+			raw = json.RawMessage("null")
+		}
+		optional := optionalFields[v.Type().Name()+"."+field.Name]
+		if err := readJSONValue(raw, v.Field(i), path+"."+name, optional); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonFieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,59 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func execJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var prog lox.Program
+	if err := json.Unmarshal(data, &prog); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	var out bytes.Buffer
+	if err := lox.New(lox.WithStdout(&out)).Exec(context.Background(), &prog); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	return out.String()
+}
+
+func TestProgramJSONRoundTrip(t *testing.T) {
+	prog, err := lox.Compile("var a = 1;\ntry { throw a; } catch (e) { print e; }\ntry { print 2; } finally { print 3; }\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	data, err := json.Marshal(prog)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	// declared names are tokens like any other, positions included
+	for _, member := range []string{
+		`"name":{"type":"IDENTIFIER","literal":"a","line":1,"column":5}`,
+		`"catchName":{"type":"IDENTIFIER","literal":"e","line":2,"column":25}`,
+	} {
+		if !strings.Contains(string(data), member) {
+			t.Errorf("JSON lacks %v:\n%s", member, data)
+		}
+	}
+
+	var decoded lox.Program
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	again, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("round trip changed the tree:\ngot  %s\nwant %s", again, data)
+	}
+	if out := execJSON(t, data); out != "1\n2\n3\n" {
+		t.Errorf("output = %q, want %q", out, "1\n2\n3\n")
+	}
+}
@@ -0,0 +1,256 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Bind exposes the Go function fn to scripts as the global name.
+//
+// Parameters may be numeric kinds, string, bool, an empty interface, or a
+// struct, map, slice or array exchanged with scripts as a HostObject. fn may
+// return nothing, one such value, an error, or a value followed by an error; a
+// non-nil error surfaces in the script as a RuntimeError, as does a panic in
+// fn. Bind reports an error if fn's signature cannot be converted.
+func (i *Interpreter) Bind(name string, fn any) error {
+	native, err := bindFunc(name, reflect.ValueOf(fn), nil)
+	if err != nil {
+		return err
+	}
+	return i.evaluator.env.DefineVar(name, native)
+}
+
+// bindFunc wraps fn as a NativeFunction. Objects it returns are restricted to
+// the members in allow.
+func bindFunc(name string, fn reflect.Value, allow map[string]bool) (*NativeFunction, error) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("bind %v: expected a function, got %v", name, fn.Kind())
+	}
+	t := fn.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("bind %v: variadic functions are not supported", name)
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		if !isConvertible(t.In(i)) {
+			return nil, fmt.Errorf("bind %v: parameter %v has unsupported type %v", name, i+1, t.In(i))
+		}
+	}
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) != errorType && !isConvertible(t.Out(0)) {
+			return nil, fmt.Errorf("bind %v: result has unsupported type %v", name, t.Out(0))
+		}
+	case 2:
+		if !isConvertible(t.Out(0)) || t.Out(1) != errorType {
+			return nil, fmt.Errorf("bind %v: results must be (value, error), got (%v, %v)", name, t.Out(0), t.Out(1))
+		}
+	default:
+		return nil, fmt.Errorf("bind %v: too many results", name)
+	}
+
+	call := func(e *Evaluator, args []Value) (value Value, err error) {
+		// a panicking host function fails the script, not the program
+		defer func() {
+			if r := recover(); r != nil {
+				value, err = nil, RuntimeError{fmt.Errorf("%v: panic: %v", name, r), 0, false}
+			}
+		}()
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			v, err := toGo(arg, t.In(i))
+			if err != nil {
+				return nil, RuntimeError{fmt.Errorf("%v: argument %v: %w", name, i+1, err), 0, false}
+			}
+			in[i] = v
+		}
+		out := fn.Call(in)
+		if n := len(out); n > 0 && t.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return nil, RuntimeError{err, 0, false}
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		result, err := fromGo(out[0], allow)
+		if err != nil {
+			return nil, RuntimeError{fmt.Errorf("%v: result: %w", name, err), 0, false}
+		}
+		return result, nil
+	}
+	return &NativeFunction{name: name, arity: t.NumIn(), fn: call}, nil
+}
+
+// Decode stores the Lox value v in the Go value pointed to by out, converting
+// it as Bind converts function arguments.
+func Decode(v Value, out any) error {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("decode: expected a non-nil pointer, got %T", out)
+	}
+	converted, err := toGo(v, ptr.Elem().Type())
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	ptr.Elem().Set(converted)
+	return nil
+}
+
+func isConvertible(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.String, reflect.Bool:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return isHostKind(t)
+}
+
+// toGo converts a Lox value to a Go value of type t.
+func toGo(v Value, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, ok := v.(float64)
+		if !ok {
+			return out, typeMismatch("number", v)
+		}
+		out.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := v.(float64)
+		if !ok {
+			return out, typeMismatch("integer", v)
+		}
+		if f != math.Trunc(f) || f < -1<<63 || f >= 1<<63 || out.OverflowInt(int64(f)) {
+			return out, fmt.Errorf("%v does not fit in %v", Stringify(f), t)
+		}
+		out.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := v.(float64)
+		if !ok {
+			return out, typeMismatch("integer", v)
+		}
+		if f != math.Trunc(f) || f < 0 || f >= 1<<64 || out.OverflowUint(uint64(f)) {
+			return out, fmt.Errorf("%v does not fit in %v", Stringify(f), t)
+		}
+		out.SetUint(uint64(f))
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return out, typeMismatch("string", v)
+		}
+		out.SetString(s)
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return out, typeMismatch("boolean", v)
+		}
+		out.SetBool(b)
+	case reflect.Interface:
+		value := v
+		if host, ok := v.(*HostObject); ok {
+			value = host.v.Interface()
+		}
+		if value != nil {
+			// interfaces with methods only take values that implement them
+			rv := reflect.ValueOf(value)
+			if !rv.Type().AssignableTo(t) {
+				if _, ok := v.(*HostObject); ok {
+					return out, fmt.Errorf("expected %v, got %v", t, rv.Type())
+				}
+				return out, typeMismatch(t.String(), v)
+			}
+			out.Set(rv)
+		}
+	default:
+		if v == nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Map || t.Kind() == reflect.Slice) {
+			return out, nil
+		}
+		host, ok := v.(*HostObject)
+		if !ok {
+			return out, typeMismatch(t.String(), v)
+		}
+		switch {
+		case host.v.Type().AssignableTo(t):
+			out.Set(host.v)
+		case host.target().Type().AssignableTo(t):
+			out.Set(host.target())
+		default:
+			return out, fmt.Errorf("expected %v, got %v", t, host.v.Type())
+		}
+	}
+	return out, nil
+}
+
+// fromGo converts a Go value to a Lox value, wrapping structs, maps, slices and
+// arrays in a HostObject restricted to the members in allow.
+func fromGo(v reflect.Value, allow map[string]bool) (Value, error) {
+	// values that came from the script can be handed back unchanged
+	if v.IsValid() && v.CanInterface() {
+		switch value := v.Interface().(type) {
+		case Callable, ErrorValue, *HostObject:
+			return value, nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return fromGo(v.Elem(), allow)
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return bindFunc("<host fn>", v, allow)
+	}
+	if isHostKind(v.Type()) {
+		return newHostObject(v, allow), nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", v.Type())
+}
+
+func typeMismatch(want string, got Value) error {
+	return fmt.Errorf("expected %v, got %v", want, typeName(got))
+}
+
+// typeName describes the Lox type of v for error messages.
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case Callable:
+		return "function"
+	}
+	return "object"
+}
@@ -0,0 +1,65 @@
+package lox_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+type counter struct {
+	hits map[string]int
+}
+
+func (c *counter) Hit(name string) float64 {
+	c.hits[name]++ // panics on the zero counter's nil map
+	return float64(c.hits[name])
+}
+
+func TestBindPanic(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+		line    int
+	}{
+		{"function", "print 1;\nexplode();", "explode: panic: boom", 2},
+		{"runtime error", "print 1;\n\nindex(3);", "index: panic: runtime error: index out of range", 3},
+		{"method", "counter.Hit(\"a\");", "Hit: panic: assignment to entry in nil map", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := lox.New(lox.WithStdout(io.Discard), lox.WithStderr(io.Discard))
+			interp.Bind("explode", func() { panic("boom") })
+			interp.Bind("index", func(i int) float64 { return []float64{1}[i] })
+			interp.BindObject("counter", &counter{})
+			err := interp.Run(context.Background(), tt.source)
+			var rerr lox.RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Run error = %v, want a RuntimeError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+			if rerr.Line() != tt.line {
+				t.Errorf("line = %v, want %v", rerr.Line(), tt.line)
+			}
+		})
+	}
+}
+
+func TestBindPanicCaught(t *testing.T) {
+	var out strings.Builder
+	interp := lox.New(lox.WithStdout(&out))
+	interp.Bind("explode", func() { panic("boom") })
+	source := "try { explode(); } catch (e) { print \"caught\"; }\nprint \"after\";"
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out.String() != "caught\nafter\n" {
+		t.Errorf("output = %q, want %q", out.String(), "caught\nafter\n")
+	}
+}
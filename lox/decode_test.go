@@ -0,0 +1,69 @@
+package lox_test
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// decodeInto decodes v into a new T, returning what it holds.
+func decodeInto[T any](v lox.Value) (any, error) {
+	var out T
+	err := lox.Decode(v, &out)
+	return out, err
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		decode  func() (any, error)
+		want    any
+		wantErr string
+	}{
+		{"number", func() (any, error) { return decodeInto[float64](1.5) }, 1.5, ""},
+		{"integer", func() (any, error) { return decodeInto[int](3.0) }, 3, ""},
+		{"string", func() (any, error) { return decodeInto[string]("s") }, "s", ""},
+		{"empty interface", func() (any, error) { return decodeInto[any](true) }, true, ""},
+		{"nil into a pointer", func() (any, error) { return decodeInto[*int](nil) }, (*int)(nil), ""},
+		{"wrong type", func() (any, error) { return decodeInto[string](1.0) }, nil, "decode: expected string, got number"},
+		{"fraction into an integer", func() (any, error) { return decodeInto[int](1.5) }, nil, "decode: 1.5 does not fit in int"},
+		{
+			"interface with methods",
+			func() (any, error) { return decodeInto[fmt.Stringer](1.0) },
+			nil, "decode: expected fmt.Stringer, got number",
+		},
+		{
+			"nil into an interface with methods",
+			func() (any, error) { return decodeInto[fmt.Stringer](nil) },
+			fmt.Stringer(nil), "",
+		},
+		{"smallest int64", func() (any, error) { return decodeInto[int64](float64(-1 << 63)) }, int64(math.MinInt64), ""},
+		{"largest number below 2^63", func() (any, error) { return decodeInto[int64](float64(1<<63 - 1024)) }, int64(1<<63 - 1024), ""},
+		{"2^63 into an int64", func() (any, error) { return decodeInto[int64](float64(1 << 63)) }, nil, "does not fit in int64"},
+		{"below the smallest int64", func() (any, error) { return decodeInto[int64](float64(-1<<63 - 2048)) }, nil, "does not fit in int64"},
+		{"2^64 into a uint64", func() (any, error) { return decodeInto[uint64](float64(1 << 64)) }, nil, "does not fit in uint64"},
+		{"largest number below 2^64", func() (any, error) { return decodeInto[uint64](float64(1<<64 - 2048)) }, uint64(1<<64 - 2048), ""},
+		{"non-pointer", func() (any, error) { return nil, lox.Decode(1.0, 1) }, nil, "decode: expected a non-nil pointer, got int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decode()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,745 @@
+package lox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Visitor interface {
+	VisitNumberExpr(NumberExpr) (float64, error)
+	VisitStringExpr(StringExpr) (string, error)
+	VisitUnaryExpr(UnaryExpr) (any, error)
+	VisitBinaryExpr(BinaryExpr) (any, error)
+	VisitBoolExpr(BoolExpr) (any, error)
+	VisitIdentifierExpr(IdentifierExpr) (any, error)
+	VisitGroupExpr(GroupExpr) (any, error)
+	VisitNilExpr(NilExpr) (any, error)
+	VisitAssignmentExpr(AssignmentExpr) (any, error)
+	VisitGetExpr(GetExpr) (any, error)
+	VisitCallExpr(CallExpr) (any, error)
+	VisitSetExpr(SetExpr) (any, error)
+	VisitIndexExpr(IndexExpr) (any, error)
+	VisitSetIndexExpr(SetIndexExpr) (any, error)
+	VisitPrintStmt(PrintStmt) error
+	VisitExpressionStmt(ExpressionStmt) error
+	VisitVarDeclStmt(VarDeclStmt) error
+	VisitBlockStmt(BlockStmt) error
+	VisitThrowStmt(ThrowStmt) error
+	VisitTryStmt(TryStmt) error
+	VisitFunDeclStmt(FunDeclStmt) error
+	VisitReturnStmt(ReturnStmt) error
+}
+
+type RuntimeError struct {
+	wrapped  error
+	line     int
+	reported bool // passed to the observers' Error callbacks
+}
+
+// ThrowError carries a value raised by a throw statement until it is caught.
+type ThrowError struct {
+	value    any
+	line     int
+	reported bool
+}
+
+func (t ThrowError) Error() string {
+	return fmt.Sprintf("uncaught exception: %v", t.value)
+}
+
+// ErrorValue is the value a catch clause binds when it catches a RuntimeError.
+type ErrorValue struct {
+	Message string
+	Line    int
+}
+
+func (e ErrorValue) String() string {
+	return e.Message
+}
+
+func (e ErrorValue) getField(name string) (Value, error) {
+	switch name {
+	case "message":
+		return e.Message, nil
+	case "line":
+		return float64(e.Line), nil
+	}
+	return nil, fmt.Errorf("Undefined property '%v'.", name)
+}
+
+// fieldGetter is implemented by values whose fields can be read with dot syntax.
+type fieldGetter interface {
+	getField(name string) (Value, error)
+}
+
+// fieldSetter is implemented by values whose fields can be assigned with dot
+// syntax.
+type fieldSetter interface {
+	setField(name string, value Value) error
+}
+
+// indexer is implemented by values whose elements can be read and assigned
+// with bracket syntax.
+type indexer interface {
+	getIndex(index Value) (Value, error)
+	setIndex(index Value, value Value) error
+}
+
+type Environment struct {
+	values map[string]any
+	outer  *Environment
+	trace  *Tracer
+}
+
+func NewEnvironment(trace *Tracer, outer *Environment) *Environment {
+	return &Environment{
+		values: make(map[string]any),
+		trace:  trace,
+		outer:  outer,
+	}
+}
+
+func (e Environment) DefineVar(name string, value any) error {
+	if e.trace.Enabled(TraceEnv) {
+		e.trace.Log(TraceEnv, "define", "name", name, "value", Stringify(value))
+	}
+	e.values[name] = value
+	return nil
+}
+
+// AssignVar sets an existing variable in the nearest scope that declares it.
+func (e Environment) AssignVar(name string, value any) error {
+	if _, ok := e.values[name]; ok {
+		if e.trace.Enabled(TraceEnv) {
+			e.trace.Log(TraceEnv, "assign", "name", name, "value", Stringify(value))
+		}
+		e.values[name] = value
+		return nil
+	}
+	if e.outer != nil {
+		return e.outer.AssignVar(name, value)
+	}
+	return RuntimeError{fmt.Errorf("Undefined variable '%v'.", name), 0, false}
+}
+
+func (e Environment) GetVar(name string) (any, error) {
+	expr, ok := e.values[name]
+	if ok {
+		if e.trace.Enabled(TraceEnv) {
+			e.trace.Log(TraceEnv, "get", "name", name, "value", Stringify(expr))
+		}
+		return expr, nil
+	}
+	if e.outer != nil {
+		return e.outer.GetVar(name)
+	}
+	e.trace.Log(TraceEnv, "undefined", "name", name)
+	return nil, RuntimeError{fmt.Errorf("unknown variable '%v'", name), 0, false}
+}
+
+// LimitError reports that a script exceeded one of the evaluator's resource
+// limits. Unlike a RuntimeError it cannot be caught by the script.
+type LimitError struct {
+	wrapped  error
+	reported bool
+}
+
+func (l LimitError) Error() string {
+	return l.wrapped.Error()
+}
+
+func (l LimitError) Unwrap() error {
+	return l.wrapped
+}
+
+func (r RuntimeError) Error() string {
+	return fmt.Sprintf("%v", r.wrapped)
+}
+
+func (r RuntimeError) Unwrap() error {
+	return r.wrapped
+}
+
+func (r RuntimeError) Line() int {
+	return r.line
+}
+
+// DefaultMaxDepth bounds how deeply the parser and evaluator may recurse
+// before reporting an error instead of overflowing the Go stack.
+const DefaultMaxDepth = 10000
+
+type Evaluator struct {
+	env          *Environment
+	trace        *Tracer
+	depth        int
+	maxDepth     int
+	ctx          context.Context
+	steps        int
+	maxSteps     int
+	maxStringLen int
+	out          *bufio.Writer
+	errOut       io.Writer
+	in           *bufio.Reader
+	observers    []Observer
+}
+
+// Flush writes any buffered script output to the output stream.
+func (e *Evaluator) Flush() error {
+	return e.out.Flush()
+}
+
+func (e *Evaluator) VisitNumberExpr(n NumberExpr) (float64, error) {
+	return n.Value, nil
+}
+
+func (e *Evaluator) VisitStringExpr(n StringExpr) (string, error) {
+	return n.Value, nil
+}
+
+func (e *Evaluator) VisitUnaryExpr(u UnaryExpr) (any, error) {
+	val, err := e.EvalExpr(u.Operand)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Op.Type {
+	case TokenMinus:
+		if !isNumber(val) {
+			return nil, RuntimeError{fmt.Errorf("Operand must be a number"), u.Op.Line, false}
+		}
+		floatVal := val.(float64)
+		return -floatVal, nil
+	case TokenBang:
+		if boolVal, ok := val.(bool); ok {
+			return !boolVal, nil
+		}
+		if val == nil {
+			return true, nil
+		}
+		if _, ok := val.(float64); ok {
+			return false, nil
+		}
+		return false, nil
+	default:
+		return 0, RuntimeError{fmt.Errorf("unary expression: unsupported operator '%v'", u.Op.Literal), u.Op.Line, false}
+	}
+}
+
+func (e *Evaluator) VisitBinaryExpr(b BinaryExpr) (any, error) {
+	leftExpr, err := e.EvalExpr(b.Left)
+	if err != nil {
+		return nil, err
+	}
+	leftNum, ok := leftExpr.(NumberExpr)
+	if ok {
+		leftExpr = leftNum.Value
+	}
+	if b.Op.Type == TokenAnd || b.Op.Type == TokenOr {
+		// the left operand decides the result unless it is true for and,
+		// or false for or
+		opPos := Pos{b.Op.Line, b.Op.Column}
+		if asBool(leftExpr) == (b.Op.Type == TokenOr) {
+			e.branch(opPos, 0)
+			return leftExpr, nil
+		}
+		e.branch(opPos, 1)
+		return e.EvalExpr(b.Right)
+	}
+	rightExpr, err := e.EvalExpr(b.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	rightNum, ok := rightExpr.(NumberExpr)
+	if ok {
+		rightExpr = rightNum.Value
+	}
+	if e.trace.Enabled(TraceEval) {
+		e.trace.Log(TraceEval, "binary", "line", b.Op.Line, "op", b.Op.Literal, "left", Stringify(leftExpr), "right", Stringify(rightExpr))
+	}
+	switch b.Op.Type {
+	case TokenPlus:
+		if isNumber(leftExpr) && isNumber(rightExpr) {
+			left, _ := leftExpr.(float64)
+			right, _ := rightExpr.(float64)
+			return left + right, nil
+		} else if isString(leftExpr) && isString(rightExpr) {
+			result := fmt.Sprintf("%v%v", leftExpr, rightExpr)
+			if err := e.checkString(result); err != nil {
+				return nil, err
+			}
+			return result, nil
+		}
+		return nil, RuntimeError{fmt.Errorf("Both operands must be numbers or strings"), b.Op.Line, false}
+	case TokenMinus:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		return left - right, nil
+	case TokenStar:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		return left * right, nil
+	case TokenSlash:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		if right == 0.0 {
+			return nil, RuntimeError{fmt.Errorf("Division by 0 is not allowed"), b.Op.Line, false}
+		}
+		return left / right, nil
+	case TokenLess:
+		if isNumber(leftExpr) && isNumber(rightExpr) {
+			left, _ := leftExpr.(float64)
+			right, _ := rightExpr.(float64)
+			return left < right, nil
+		}
+		return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+	case TokenLessEqual:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		return left <= right, nil
+	case TokenGreater:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		return left > right, nil
+	case TokenGreaterEqual:
+		if !isNumber(leftExpr) || !isNumber(rightExpr) {
+			return nil, RuntimeError{fmt.Errorf("Operands must be numbers"), b.Op.Line, false}
+		}
+		left, _ := leftExpr.(float64)
+		right, _ := rightExpr.(float64)
+		return left >= right, nil
+	case TokenEqualEqual:
+		return leftExpr == rightExpr, nil
+	case TokenBangEqual:
+		if isNumber(leftExpr) && isNumber(rightExpr) {
+			left, _ := leftExpr.(float64)
+			right, _ := rightExpr.(float64)
+			return left != right, nil
+		} else if isString(leftExpr) && isString(rightExpr) {
+			return leftExpr != rightExpr, nil
+		}
+		leftBool := asBool(leftExpr)
+		rightBool := asBool(rightExpr)
+		return leftBool != rightBool, nil
+	default:
+		return nil, RuntimeError{fmt.Errorf("binary expression: unsupported operand '%v'", b.Op.Type), b.Op.Line, false}
+	}
+
+}
+
+func (e *Evaluator) VisitIdentifierExpr(b IdentifierExpr) (any, error) {
+	value, err := e.get(b.Value)
+	if rerr, ok := err.(RuntimeError); ok && rerr.line == 0 {
+		rerr.line = b.Line
+		return nil, rerr
+	}
+	return value, err
+}
+
+func (e *Evaluator) VisitGetExpr(g GetExpr) (any, error) {
+	object, err := e.EvalExpr(g.Object)
+	if err != nil {
+		return nil, err
+	}
+	getter, ok := object.(fieldGetter)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("Only objects have properties."), g.Name.Line, false}
+	}
+	value, err := getter.getField(g.Name.Literal)
+	if err != nil {
+		return nil, RuntimeError{err, g.Name.Line, false}
+	}
+	if err := e.checkString(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (e *Evaluator) VisitSetExpr(s SetExpr) (any, error) {
+	object, err := e.EvalExpr(s.Object)
+	if err != nil {
+		return nil, err
+	}
+	setter, ok := object.(fieldSetter)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("Only objects have fields."), s.Name.Line, false}
+	}
+	value, err := e.EvalExpr(s.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := setter.setField(s.Name.Literal, value); err != nil {
+		return nil, RuntimeError{err, s.Name.Line, false}
+	}
+	return value, nil
+}
+
+func (e *Evaluator) VisitIndexExpr(i IndexExpr) (any, error) {
+	object, err := e.EvalExpr(i.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.EvalExpr(i.Index)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := object.(indexer)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("Only lists and maps can be indexed."), i.Bracket.Line, false}
+	}
+	value, err := target.getIndex(index)
+	if err != nil {
+		return nil, RuntimeError{err, i.Bracket.Line, false}
+	}
+	if err := e.checkString(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (e *Evaluator) VisitSetIndexExpr(s SetIndexExpr) (any, error) {
+	object, err := e.EvalExpr(s.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.EvalExpr(s.Index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := e.EvalExpr(s.Value)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := object.(indexer)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("Only lists and maps can be indexed."), s.Bracket.Line, false}
+	}
+	if err := target.setIndex(index, value); err != nil {
+		return nil, RuntimeError{err, s.Bracket.Line, false}
+	}
+	return value, nil
+}
+
+func (e *Evaluator) VisitCallExpr(c CallExpr) (any, error) {
+	callee, err := e.EvalExpr(c.Callee)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, 0, len(c.Args))
+	for _, arg := range c.Args {
+		value, err := e.EvalExpr(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+	fn, ok := callee.(Callable)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("Can only call functions and classes."), c.Paren.Line, false}
+	}
+	if fn.Arity() != len(args) {
+		return nil, RuntimeError{fmt.Errorf("Expected %v arguments but got %v.", fn.Arity(), len(args)), c.Paren.Line, false}
+	}
+	result, err := e.call(fn, args, c.Paren.Line)
+	if rerr, ok := err.(RuntimeError); ok && rerr.line == 0 {
+		rerr.line = c.Paren.Line
+		return nil, rerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkString(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (e *Evaluator) VisitBoolExpr(b BoolExpr) (any, error) {
+	return b.Value, nil
+}
+
+func (e *Evaluator) VisitGroupExpr(b GroupExpr) (any, error) {
+	return e.EvalExpr(b.Expression)
+}
+
+func (e *Evaluator) VisitNilExpr(b NilExpr) (any, error) {
+	return nil, nil
+}
+
+func (e *Evaluator) VisitPrintStmt(p PrintStmt) error {
+	v, err := e.EvalExpr(p.Expression)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, Stringify(v))
+	return nil
+}
+
+func (e *Evaluator) VisitBlockStmt(b BlockStmt) error {
+	return e.evalBlock(b.Body, NewEnvironment(e.trace, e.env))
+}
+
+func (e *Evaluator) VisitExpressionStmt(s ExpressionStmt) error {
+	_, err := e.EvalExpr(s.Expression)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *Evaluator) VisitThrowStmt(t ThrowStmt) error {
+	value, err := e.EvalExpr(t.Expression)
+	if err != nil {
+		return err
+	}
+	return ThrowError{value: value, line: t.Line}
+}
+
+func (e *Evaluator) VisitTryStmt(t TryStmt) error {
+	err := e.VisitBlockStmt(t.Body)
+	if err == nil && t.Catch != nil {
+		e.branch(t.Pos, 0)
+	}
+	if err != nil && t.Catch != nil {
+		if value, ok := caughtValue(err); ok {
+			e.branch(t.Pos, 1)
+			env := NewEnvironment(e.trace, e.env)
+			e.define(env, t.CatchName.Literal, value)
+			err = e.evalBlock(t.Catch.Body, env)
+		}
+	}
+	if t.Finally != nil {
+		if finallyErr := e.VisitBlockStmt(*t.Finally); finallyErr != nil {
+			return finallyErr
+		}
+	}
+	return err
+}
+
+// caughtValue converts an error into the value a catch clause binds, reporting
+// false for errors scripts must not be able to intercept.
+func caughtValue(err error) (any, bool) {
+	switch err := err.(type) {
+	case ThrowError:
+		return err.value, true
+	case RuntimeError:
+		return ErrorValue{Message: err.Error(), Line: err.line}, true
+	}
+	return nil, false
+}
+
+func (e *Evaluator) VisitVarDeclStmt(p VarDeclStmt) error {
+	value, err := e.EvalExpr(p.Expression)
+	if err != nil {
+		return err
+	}
+	return e.define(e.env, p.Name.Literal, value)
+}
+
+func (e *Evaluator) VisitAssignmentExpr(p AssignmentExpr) (any, error) {
+	_, err := e.env.GetVar(p.Identifier.Literal)
+	if err != nil {
+		return nil, err
+	}
+	value, err := e.EvalExpr(p.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.assign(p.Identifier.Literal, value); err != nil {
+		return nil, RuntimeError{err, p.Identifier.Line, false}
+	}
+	return value, nil
+}
+
+func (e *Evaluator) VisitFunDeclStmt(f FunDeclStmt) error {
+	return e.define(e.env, f.Name.Literal, &LoxFunction{decl: f, closure: e.env})
+}
+
+func (e *Evaluator) VisitReturnStmt(r ReturnStmt) error {
+	var value Value
+	if r.Value != nil {
+		var err error
+		if value, err = e.EvalExpr(r.Value); err != nil {
+			return err
+		}
+	}
+	return returnValue{value}
+}
+
+func (e *Evaluator) EvalExpr(expr Expression) (any, error) {
+	if err := e.enter(); err != nil {
+		return nil, err
+	}
+	defer e.leave()
+	value, err := expr.accept(e)
+	for _, o := range e.observers {
+		o.ExprEval(e, expr, value, err)
+	}
+	return value, err
+}
+
+func (e *Evaluator) execute(s Statement) error {
+	if err := e.enter(); err != nil {
+		return err
+	}
+	defer e.leave()
+	if e.trace.Enabled(TraceEval) {
+		e.trace.Log(TraceEval, "execute", "stmt", fmt.Sprintf("%T", s), "depth", e.depth)
+	}
+	if len(e.observers) == 0 {
+		return s.accept(e)
+	}
+	for _, o := range e.observers {
+		o.StmtEnter(e, s)
+	}
+	err := s.accept(e)
+	if err != nil {
+		var first bool
+		if err, first = markReported(err); first {
+			for _, o := range e.observers {
+				o.Error(e, err)
+			}
+		}
+	}
+	for _, o := range e.observers {
+		o.StmtExit(e, s, err)
+	}
+	return err
+}
+
+// markReported flags err as reported to the observers, so that the statements
+// it escapes from after the innermost one, including those of finally blocks
+// that run while it propagates, do not report it again. It returns false if err
+// was already reported or is not an error the script raised.
+func markReported(err error) (error, bool) {
+	switch err := err.(type) {
+	case RuntimeError:
+		first := !err.reported
+		err.reported = true
+		return err, first
+	case ThrowError:
+		first := !err.reported
+		err.reported = true
+		return err, first
+	case LimitError:
+		first := !err.reported
+		err.reported = true
+		return err, first
+	case returnValue:
+		return err, false
+	}
+	return err, true
+}
+
+func (e *Evaluator) enter() error {
+	e.steps++
+	if e.maxSteps > 0 && e.steps > e.maxSteps {
+		return LimitError{fmt.Errorf("Step limit of %v exceeded.", e.maxSteps), false}
+	}
+	// a single long expression must not outlive its deadline either
+	if e.ctx != nil {
+		if err := e.ctx.Err(); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return LimitError{fmt.Errorf("Execution timed out: %w", err), false}
+			}
+			return LimitError{fmt.Errorf("Execution cancelled: %w", err), false}
+		}
+	}
+	// check before counting the level, as callers only leave levels they
+	// entered
+	if e.maxDepth > 0 && e.depth >= e.maxDepth {
+		return RuntimeError{fmt.Errorf("Stack overflow."), 0, false}
+	}
+	e.depth++
+	return nil
+}
+
+// checkString reports a LimitError if v is a string longer than the evaluator
+// allows, whether the script built it or input or the host handed it over.
+func (e *Evaluator) checkString(v Value) error {
+	if s, ok := v.(string); ok && e.maxStringLen > 0 && len(s) > e.maxStringLen {
+		return LimitError{fmt.Errorf("String exceeds maximum length of %v bytes.", e.maxStringLen), false}
+	}
+	return nil
+}
+
+func (e *Evaluator) leave() {
+	e.depth--
+}
+
+func (e *Evaluator) Eval(block BlockStmt) error {
+	for i := 0; i < len(block.Body); i++ {
+		s := block.Body[i]
+		if err := e.execute(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Evaluator) evalBlock(statments []Statement, env *Environment) error {
+	parentEnv := e.env
+	defer func() {
+		e.env = parentEnv
+	}()
+	e.env = env
+	for _, s := range statments {
+		if err := e.execute(s); err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+// Stringify formats a value the way print displays it.
+func Stringify(v Value) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Truthy reports whether v counts as true in a condition: everything but nil
+// and false does.
+func Truthy(v Value) bool {
+	return asBool(v)
+}
+
+func isString(a any) bool {
+	_, ok := a.(string)
+	return ok
+}
+
+func isNumber(a any) bool {
+	_, ok := a.(float64)
+	return ok
+}
+
+func asBool(a any) bool {
+	if a == nil {
+		return false
+	}
+
+	if b, ok := a.(bool); ok {
+		return b
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package lox
+
+import "strings"
+
+// Format returns source in the canonical Lox style: one statement per line,
+// blocks indented with tabs, single spaces around binary operators and after
+// commas and keywords, and at most one blank line in a row. Comments are kept
+// where they are, either on their own line or after the code they follow.
+// Source that does not parse is returned unchanged along with the error.
+func Format(source string) (string, error) {
+	if _, err := Compile(source); err != nil {
+		return source, err
+	}
+	lexer := NewLexer(source)
+	var tokens []Token
+	for tok := lexer.Next(); tok.Type != TokenEOF; tok = lexer.Next() {
+		tokens = append(tokens, tok)
+	}
+	f := &formatter{}
+	for i, tok := range tokens {
+		var next *Token
+		if i+1 < len(tokens) {
+			next = &tokens[i+1]
+		}
+		f.token(tok, next)
+	}
+	if f.sb.Len() > 0 {
+		f.sb.WriteByte('\n')
+	}
+	return f.sb.String(), nil
+}
+
+type formatter struct {
+	sb        strings.Builder
+	indent    int
+	parens    int   // open ( and [ on the current line
+	prev      Token // last code token written
+	prevUnary bool  // prev is a unary operator
+	prevEnd   int   // source line the last token written ended on
+	commented bool  // the last token written is a comment
+	newline   bool  // the next token starts a new line
+	written   bool
+}
+
+func (f *formatter) token(tok Token, next *Token) {
+	if tok.Type == TokenComment {
+		f.comment(tok)
+		return
+	}
+	if tok.Type == TokenRightBrace {
+		f.indent--
+	}
+	switch {
+	case !f.written:
+	case f.newline:
+		f.breakLine(tok)
+	case f.space(tok):
+		f.sb.WriteByte(' ')
+	}
+	f.sb.WriteString(tok.Lexeme)
+
+	f.newline = false
+	switch tok.Type {
+	case TokenLeftParen, TokenLeftBracket:
+		f.parens++
+	case TokenRightParen, TokenRightBracket:
+		f.parens--
+	case TokenSemiColon:
+		f.newline = f.parens == 0
+	case TokenLeftBrace:
+		f.indent++
+		f.newline = next == nil || next.Type != TokenRightBrace
+	case TokenRightBrace:
+		f.newline = next == nil || !closesInline(next.Type)
+	}
+	f.prevUnary = tok.Type == TokenBang || tok.Type == TokenMinus && (!f.written || !endsValue(f.prev.Type))
+	f.prev = tok
+	f.prevEnd = tok.Line + strings.Count(tok.Literal, "\n")
+	f.commented = false
+	f.written = true
+}
+
+// comment writes a comment after the code on its line, or on a line of its
+// own if nothing precedes it in the source.
+func (f *formatter) comment(tok Token) {
+	text := strings.TrimRight(tok.Lexeme, " \t\r")
+	switch {
+	case !f.written:
+	case tok.Line == f.prevEnd:
+		f.sb.WriteByte(' ')
+	default:
+		f.breakLine(tok)
+	}
+	f.sb.WriteString(text)
+	f.prevEnd = tok.Line
+	f.newline = true
+	f.commented = true
+	f.written = true
+}
+
+// breakLine starts the line for tok, keeping one blank line if the source had
+// any, except at the start or end of a block.
+func (f *formatter) breakLine(tok Token) {
+	f.sb.WriteByte('\n')
+	atStart := f.prev.Type == TokenLeftBrace && !f.commented
+	if tok.Line-f.prevEnd > 1 && tok.Type != TokenRightBrace && !atStart {
+		f.sb.WriteByte('\n')
+	}
+	f.sb.WriteString(strings.Repeat("\t", f.indent))
+}
+
+// space reports whether a space separates tok from the token before it on the
+// same line.
+func (f *formatter) space(tok Token) bool {
+	switch tok.Type {
+	case TokenSemiColon, TokenComma, TokenRightParen, TokenRightBracket, TokenDot:
+		return false
+	case TokenLeftParen, TokenLeftBracket:
+		if endsValue(f.prev.Type) && f.prev.Type != TokenNumber && f.prev.Type != TokenString {
+			return false // call or index
+		}
+	}
+	switch f.prev.Type {
+	case TokenLeftParen, TokenLeftBracket, TokenDot:
+		return false
+	case TokenLeftBrace:
+		return tok.Type != TokenRightBrace
+	}
+	return !f.prevUnary
+}
+
+// endsValue reports whether a token of type t can end an operand, so that a
+// following minus is a binary operator.
+func endsValue(t TokenType) bool {
+	switch t {
+	case TokenIdentifier, TokenNumber, TokenString, TokenRightParen, TokenRightBracket,
+		TokenTrue, TokenFalse, TokenNil, TokenThis, TokenSuper:
+		return true
+	}
+	return false
+}
+
+// closesInline reports whether a token of type t continues the line after a
+// closing brace, as in "} catch (e) {".
+func closesInline(t TokenType) bool {
+	switch t {
+	case TokenCatch, TokenFinally, TokenElse, TokenSemiColon, TokenComma, TokenRightParen:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package lox_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// comments returns the text of the comments in source.
+func comments(source string) []string {
+	var texts []string
+	l := lox.NewLexer(source)
+	for tok := l.Next(); tok.Type != lox.TokenEOF; tok = l.Next() {
+		if tok.Type == lox.TokenComment {
+			texts = append(texts, strings.TrimSpace(tok.Literal))
+		}
+	}
+	return texts
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "spacing and indentation",
+			source: "fun f(x,y){return x*-y;}\ntry{print f(1,2);}catch(e){print e;}finally{print \"done\";}\n",
+			want:   "fun f(x, y) {\n\treturn x * -y;\n}\ntry {\n\tprint f(1, 2);\n} catch (e) {\n\tprint e;\n} finally {\n\tprint \"done\";\n}\n",
+		},
+		{
+			name:   "one statement per line",
+			source: "print 1; print 2;",
+			want:   "print 1;\nprint 2;\n",
+		},
+		{
+			name:   "blank lines collapse",
+			source: "var a = 1;\n\n\n\nvar b = 2;\n",
+			want:   "var a = 1;\n\nvar b = 2;\n",
+		},
+		{
+			name:   "no blank lines at the ends of blocks",
+			source: "{\n\n  print 1;\n\n}\n",
+			want:   "{\n\tprint 1;\n}\n",
+		},
+		{
+			name:   "comments on their own lines and after code",
+			source: "// header\nvar a=1;// trailing\n\n\nfun f(x){\n// inside\nreturn x*2;}\n// footer\n",
+			want:   "// header\nvar a = 1; // trailing\n\nfun f(x) {\n\t// inside\n\treturn x * 2;\n}\n// footer\n",
+		},
+		{
+			name:   "comments at the ends of blocks",
+			source: "fun f() {\n  return 1;   // one   \n  // closing\n}\n",
+			want:   "fun f() {\n\treturn 1; // one\n\t// closing\n}\n",
+		},
+		{
+			name:   "blank line after a comment opening a block",
+			source: "{\n\n// lone\n\nprint 1;\n\n}\n",
+			want:   "{\n\t// lone\n\n\tprint 1;\n}\n",
+		},
+		{
+			name:   "comment after a statement split across lines",
+			source: "print 1 +\n  2; // sum\nprint 3;\n",
+			want:   "print 1 + 2; // sum\nprint 3;\n",
+		},
+		{
+			name:   "comment markers in strings",
+			source: "var s=\"a // not a comment\";",
+			want:   "var s = \"a // not a comment\";\n",
+		},
+		{
+			name:   "only comments",
+			source: "// one\n\n\n// two",
+			want:   "// one\n\n// two\n",
+		},
+		{
+			name:   "empty",
+			source: "",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lox.Format(tt.source)
+			if err != nil {
+				t.Fatalf("Format: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format(%q)\ngot  %q\nwant %q", tt.source, got, tt.want)
+			}
+			again, err := lox.Format(got)
+			if err != nil {
+				t.Fatalf("Format of the formatted source: %v", err)
+			}
+			if again != got {
+				t.Errorf("formatting is not idempotent:\nonce  %q\ntwice %q", got, again)
+			}
+			if c, want := comments(got), comments(tt.source); !reflect.DeepEqual(c, want) {
+				t.Errorf("comments = %q, want %q", c, want)
+			}
+		})
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	source := "var  x = ;// broken\n"
+	got, err := lox.Format(source)
+	if err == nil {
+		t.Fatal("Format succeeded on source that does not parse")
+	}
+	if got != source {
+		t.Errorf("Format changed source it could not parse to %q", got)
+	}
+}
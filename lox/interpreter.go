@@ -0,0 +1,269 @@
+package lox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Value is anything a Lox program can produce: nil, bool, float64, string or
+// one of the object types defined by this package.
+type Value = any
+
+// Interpreter runs Lox source. Globals persist across calls to Run and Eval, so
+// one Interpreter can execute a script in several pieces. An Interpreter must
+// not be used from several goroutines at once; give each goroutine its own and
+// share parsed Programs between them instead.
+type Interpreter struct {
+	trace        *Tracer
+	maxDepth     int
+	maxSteps     int
+	maxStringLen int
+	stdout       io.Writer
+	stderr       io.Writer
+	stdin        io.Reader
+	observers    []Observer
+	evaluator    *Evaluator
+}
+
+type Option func(*Interpreter)
+
+// WithTracer sends trace records for the lexer, parser and evaluator to t.
+func WithTracer(t *Tracer) Option {
+	return func(i *Interpreter) {
+		i.trace = t
+	}
+}
+
+// WithMaxDepth bounds nesting in the parser and recursion in the evaluator.
+func WithMaxDepth(n int) Option {
+	return func(i *Interpreter) {
+		i.maxDepth = n
+	}
+}
+
+// WithMaxSteps bounds the number of statements and expressions a single call to
+// Run or Eval may evaluate. Zero means no limit.
+func WithMaxSteps(n int) Option {
+	return func(i *Interpreter) {
+		i.maxSteps = n
+	}
+}
+
+// WithMaxStringLen bounds the length in bytes of strings a script builds or
+// receives, from input, bound functions or host objects. Zero means no limit.
+func WithMaxStringLen(n int) Option {
+	return func(i *Interpreter) {
+		i.maxStringLen = n
+	}
+}
+
+// WithStdout sets where print writes. Output is buffered and flushed when Run
+// or Eval returns.
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// WithStderr sets where Run, Exec and the other entry points report the errors
+// they return. By default errors are only returned.
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stderr = w
+	}
+}
+
+// WithStdin sets where input() and readLine() read from.
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.stdin = r
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	i := configure(opts)
+	i.evaluator = &Evaluator{
+		env:          NewEnvironment(i.trace, nil),
+		trace:        i.trace,
+		maxDepth:     i.maxDepth,
+		maxSteps:     i.maxSteps,
+		maxStringLen: i.maxStringLen,
+		out:          bufio.NewWriter(i.stdout),
+		errOut:       i.stderr,
+		in:           bufio.NewReader(i.stdin),
+		observers:    i.observers,
+	}
+	defineNatives(i.evaluator.env)
+	return i
+}
+
+// configure applies opts to an Interpreter that has no evaluator yet.
+func configure(opts []Option) *Interpreter {
+	i := &Interpreter{
+		maxDepth: DefaultMaxDepth,
+		stdout:   os.Stdout,
+		stderr:   io.Discard,
+		stdin:    os.Stdin,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Run parses and executes source. Errors are ScanErrors or ParseErrors when the
+// source is malformed, LimitErrors when ctx is done or a limit is exceeded, and
+// RuntimeErrors or ThrowErrors when the script fails. They are also reported to
+// the error stream set with WithStderr.
+func (i *Interpreter) Run(ctx context.Context, source string) error {
+	return i.RunReader(ctx, strings.NewReader(source))
+}
+
+// RunReader is like Run but reads the source from r. Only the Lexer reads r
+// incrementally: the tokens and syntax tree of the whole source are held in
+// memory before it runs.
+func (i *Interpreter) RunReader(ctx context.Context, r io.Reader) error {
+	block, err := i.ParseReader(r)
+	if err != nil {
+		i.finish(&err)
+		return err
+	}
+	return i.Exec(ctx, &Program{block: block})
+}
+
+// Exec executes a compiled program in the interpreter's environment.
+func (i *Interpreter) Exec(ctx context.Context, prog *Program) (err error) {
+	defer i.finish(&err)
+	i.reset(ctx)
+	return i.evaluator.Eval(prog.block)
+}
+
+// Eval evaluates a single expression against the interpreter's globals.
+func (i *Interpreter) Eval(expr string) (Value, error) {
+	return i.EvalContext(context.Background(), expr)
+}
+
+// EvalContext is like Eval but stops with a LimitError when ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, expr string) (value Value, err error) {
+	defer i.finish(&err)
+	parsed, err := i.ParseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	i.reset(ctx)
+	return i.evaluator.EvalExpr(parsed)
+}
+
+// Call calls the global function name, converting args as Bind converts the
+// results of Go functions. Use Decode to convert the result to a Go type.
+func (i *Interpreter) Call(ctx context.Context, name string, args ...any) (result Value, err error) {
+	defer i.finish(&err)
+	value, err := i.evaluator.env.GetVar(name)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := value.(Callable)
+	if !ok {
+		return nil, RuntimeError{fmt.Errorf("'%v' is not a function.", name), 0, false}
+	}
+	if fn.Arity() != len(args) {
+		return nil, RuntimeError{fmt.Errorf("Expected %v arguments but got %v.", fn.Arity(), len(args)), 0, false}
+	}
+	loxArgs := make([]Value, len(args))
+	for n, arg := range args {
+		if loxArgs[n], err = fromGo(reflect.ValueOf(arg), nil); err != nil {
+			return nil, fmt.Errorf("call %v: argument %v: %w", name, n+1, err)
+		}
+	}
+	// a host function may call back into the script while it is running
+	if i.evaluator.depth == 0 {
+		i.reset(ctx)
+	}
+	return i.evaluator.call(fn, loxArgs, 0)
+}
+
+// Global returns the value of the global variable name.
+func (i *Interpreter) Global(name string) (Value, bool) {
+	value, ok := i.evaluator.env.values[name]
+	return value, ok
+}
+
+// Globals returns the names of all global variables in sorted order.
+func (i *Interpreter) Globals() []string {
+	names := make([]string, 0, len(i.evaluator.env.values))
+	for name := range i.evaluator.env.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses source into a program without running it.
+func (i *Interpreter) Parse(source string) (BlockStmt, error) {
+	return i.ParseReader(strings.NewReader(source))
+}
+
+// ParseReader is like Parse but reads the source from r. The tokens of the
+// whole source are collected before parsing, so memory grows with its length.
+func (i *Interpreter) ParseReader(r io.Reader) (BlockStmt, error) {
+	tokens, err := i.scan(r)
+	if err != nil {
+		return BlockStmt{}, err
+	}
+	return i.newParser(tokens).Parse()
+}
+
+// ParseExpression parses source as a single expression without evaluating it.
+func (i *Interpreter) ParseExpression(source string) (Expression, error) {
+	tokens, err := i.scan(strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	return i.newParser(tokens).ParseExpression()
+}
+
+// scan lexes all of r, as the parser looks ahead and back across the tokens.
+func (i *Interpreter) scan(r io.Reader) ([]Token, error) {
+	lexer := NewLexerFromReader(r)
+	lexer.SetTracer(i.trace)
+	tokens := make([]Token, 0)
+	tok := lexer.Next()
+	for ; tok.Type != TokenEOF; tok = lexer.Next() {
+		tokens = append(tokens, tok)
+	}
+	tokens = append(tokens, tok)
+	i.trace.Log(TraceLexer, "END lexing", "tokens", len(tokens))
+	if errs := lexer.Errors(); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return tokens, nil
+}
+
+func (i *Interpreter) newParser(tokens []Token) *Parser {
+	parser := NewParser(tokens, i.trace)
+	parser.maxDepth = i.maxDepth
+	return parser
+}
+
+// finish flushes script output and reports a failed run on the error stream.
+func (i *Interpreter) finish(err *error) {
+	if flushErr := i.evaluator.Flush(); flushErr != nil && *err == nil {
+		*err = flushErr
+	}
+	if *err != nil {
+		fmt.Fprintln(i.evaluator.errOut, *err)
+	}
+}
+
+func (i *Interpreter) reset(ctx context.Context) {
+	i.evaluator.ctx = ctx
+	i.evaluator.steps = 0
+	i.evaluator.depth = 0
+}
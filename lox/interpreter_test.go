@@ -0,0 +1,137 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// run runs source in a new interpreter configured by opts, returning what it
+// printed and its error.
+func run(t *testing.T, source string, opts ...lox.Option) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	opts = append(opts, lox.WithStdout(&stdout), lox.WithStderr(&stderr))
+	err := lox.New(opts...).Run(context.Background(), source)
+	return stdout.String(), err
+}
+
+func TestCaughtStackOverflow(t *testing.T) {
+	source := "fun f(n) { return f(n + 1); }\n" +
+		strings.Repeat("try { f(0); } catch (e) {}\n", 60) +
+		"fun g() { return \"still alive\"; }\nprint g();\n"
+	out, err := run(t, source, lox.WithMaxDepth(50))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out != "still alive\n" {
+		t.Errorf("output = %q, want %q", out, "still alive\n")
+	}
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	interp := lox.New(lox.WithStderr(io.Discard))
+	if err := interp.Run(context.Background(), "fun f() { return 1; }"); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var limitErr lox.LimitError
+	if _, err := interp.EvalContext(ctx, "f()"); !errors.As(err, &limitErr) {
+		t.Errorf("EvalContext error = %v, want a LimitError", err)
+	}
+}
+
+func TestContextCancelledWithinStatement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out bytes.Buffer
+	interp := lox.New(lox.WithStdout(&out))
+	if err := interp.Bind("cancel", func() float64 { cancel(); return 0 }); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	// the only statement must stop once its first operand cancels the context
+	var limitErr lox.LimitError
+	if err := interp.Run(ctx, "print cancel() + 1;"); !errors.As(err, &limitErr) {
+		t.Errorf("Run error = %v, want a LimitError", err)
+	}
+	if out.String() != "" {
+		t.Errorf("output = %q, want none", out.String())
+	}
+}
+
+type document struct {
+	Body string
+}
+
+func TestMaxStringLen(t *testing.T) {
+	long := strings.Repeat("x", 11)
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"concatenation", `var s = "xxxxxx" + "xxxxx";`},
+		{"input", "var s = readLine();"},
+		{"bound function result", "var s = long();"},
+		{"host object field", "var s = doc.Body;"},
+		{"host object element", "var s = lines[0];"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := lox.New(lox.WithMaxStringLen(10), lox.WithStdin(strings.NewReader(long+"\n")))
+			if err := interp.Bind("long", func() string { return long }); err != nil {
+				t.Fatalf("Bind: %v", err)
+			}
+			if err := interp.BindObject("doc", &document{Body: long}); err != nil {
+				t.Fatalf("BindObject: %v", err)
+			}
+			if err := interp.BindObject("lines", []string{long}); err != nil {
+				t.Fatalf("BindObject: %v", err)
+			}
+			var limitErr lox.LimitError
+			if err := interp.Run(context.Background(), tt.source); !errors.As(err, &limitErr) {
+				t.Errorf("Run error = %v, want a LimitError", err)
+			}
+		})
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+func TestRuntimeErrorUnwrap(t *testing.T) {
+	interp := lox.New()
+	if err := interp.Bind("lookup", func(key string) (string, error) { return "", errNotFound }); err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	err := interp.Run(context.Background(), `lookup("a");`)
+	var rerr lox.RuntimeError
+	if !errors.As(err, &rerr) || !errors.Is(err, errNotFound) {
+		t.Errorf("Run error = %v, want a RuntimeError wrapping %v", err, errNotFound)
+	}
+}
+
+func TestErrorsNotReportedByDefault(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	interp := lox.New(lox.WithStdout(io.Discard))
+	if err := interp.Run(context.Background(), "print -nil;"); err == nil {
+		t.Fatal("Run succeeded, want an error")
+	}
+	w.Close()
+	if b, _ := io.ReadAll(r); len(b) > 0 {
+		t.Errorf("Run wrote %q to standard error", b)
+	}
+}
@@ -1,10 +1,13 @@
-package main
+package lox
 
 import (
-	"errors"
+	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TokenType int
@@ -51,6 +54,12 @@ const (
 	TokenTrue
 	TokenVar
 	TokenWhile
+	TokenTry
+	TokenCatch
+	TokenFinally
+	TokenThrow
+	TokenLeftBracket
+	TokenRightBracket
 	TokenIllegal
 )
 
@@ -91,6 +100,12 @@ var TokenTypeStr = map[TokenType]string{
 	TokenTrue:         "TRUE",
 	TokenVar:          "VAR",
 	TokenWhile:        "WHILE",
+	TokenTry:          "TRY",
+	TokenCatch:        "CATCH",
+	TokenFinally:      "FINALLY",
+	TokenThrow:        "THROW",
+	TokenLeftBracket:  "[",
+	TokenRightBracket: "]",
 	TokenNumber:       "NUMBER",
 }
 
@@ -101,34 +116,127 @@ func (t TokenType) String() string {
 	return ""
 }
 
+var tokenNames = map[TokenType]string{
+	TokenEOF:          "EOF",
+	TokenNumber:       "NUMBER",
+	TokenString:       "STRING",
+	TokenLeftParen:    "LEFT_PAREN",
+	TokenRightParen:   "RIGHT_PAREN",
+	TokenLeftBrace:    "LEFT_BRACE",
+	TokenRightBrace:   "RIGHT_BRACE",
+	TokenComma:        "COMMA",
+	TokenColon:        "COLON",
+	TokenSemiColon:    "SEMICOLON",
+	TokenPlus:         "PLUS",
+	TokenMinus:        "MINUS",
+	TokenDot:          "DOT",
+	TokenSlash:        "SLASH",
+	TokenStar:         "STAR",
+	TokenEqualEqual:   "EQUAL_EQUAL",
+	TokenBangEqual:    "BANG_EQUAL",
+	TokenLessEqual:    "LESS_EQUAL",
+	TokenGreaterEqual: "GREATER_EQUAL",
+	TokenEqual:        "EQUAL",
+	TokenBang:         "BANG",
+	TokenLess:         "LESS",
+	TokenComment:      "COMMENT",
+	TokenGreater:      "GREATER",
+	TokenIdentifier:   "IDENTIFIER",
+	TokenAnd:          "AND",
+	TokenClass:        "CLASS",
+	TokenElse:         "ELSE",
+	TokenFalse:        "FALSE",
+	TokenFor:          "FOR",
+	TokenFun:          "FUN",
+	TokenIf:           "IF",
+	TokenNil:          "NIL",
+	TokenOr:           "OR",
+	TokenPrint:        "PRINT",
+	TokenReturn:       "RETURN",
+	TokenSuper:        "SUPER",
+	TokenThis:         "THIS",
+	TokenTrue:         "TRUE",
+	TokenVar:          "VAR",
+	TokenWhile:        "WHILE",
+	TokenTry:          "TRY",
+	TokenCatch:        "CATCH",
+	TokenFinally:      "FINALLY",
+	TokenThrow:        "THROW",
+	TokenLeftBracket:  "LEFT_BRACKET",
+	TokenRightBracket: "RIGHT_BRACKET",
+	TokenIllegal:      "ILLEGAL",
+}
+
+// Name returns the upper case name of the token type, such as "LEFT_PAREN",
+// as used in the tokenize output.
+func (t TokenType) Name() string {
+	return tokenNames[t]
+}
+
+// TokenTypeByName returns the token type with the given Name.
+func TokenTypeByName(name string) (TokenType, bool) {
+	for t, n := range tokenNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
 var Keywords = map[string]TokenType{
-	"and":    TokenAnd,
-	"class":  TokenClass,
-	"else":   TokenElse,
-	"false":  TokenFalse,
-	"for":    TokenFor,
-	"fun":    TokenFun,
-	"if":     TokenIf,
-	"nil":    TokenNil,
-	"or":     TokenOr,
-	"print":  TokenPrint,
-	"return": TokenReturn,
-	"super":  TokenSuper,
-	"this":   TokenThis,
-	"true":   TokenTrue,
-	"var":    TokenVar,
-	"while":  TokenWhile,
+	"and":     TokenAnd,
+	"class":   TokenClass,
+	"else":    TokenElse,
+	"false":   TokenFalse,
+	"for":     TokenFor,
+	"fun":     TokenFun,
+	"if":      TokenIf,
+	"nil":     TokenNil,
+	"or":      TokenOr,
+	"print":   TokenPrint,
+	"return":  TokenReturn,
+	"super":   TokenSuper,
+	"this":    TokenThis,
+	"true":    TokenTrue,
+	"var":     TokenVar,
+	"while":   TokenWhile,
+	"try":     TokenTry,
+	"catch":   TokenCatch,
+	"finally": TokenFinally,
+	"throw":   TokenThrow,
 }
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Lexeme  string // the token's source text
+	Line    int
+	Column  int
+}
+
+// Position returns where the token starts in the source.
+func (t Token) Position() Pos {
+	return Pos{Line: t.Line, Column: t.Column}
 }
 
 func (t Token) Lit() string {
 	return t.Literal
 }
 
+// Value returns the literal value of a number or string token, as a float64 or
+// the string's contents, and nil for other tokens.
+func (t Token) Value() any {
+	switch t.Type {
+	case TokenNumber:
+		if f, err := strconv.ParseFloat(t.Literal, 64); err == nil {
+			return f
+		}
+	case TokenString:
+		return t.Literal
+	}
+	return nil
+}
+
 func (t Token) String() string {
 	switch t.Type {
 	case TokenNumber:
@@ -153,6 +261,10 @@ func (t Token) String() string {
 		return fmt.Sprintf("RIGHT_BRACE %v null", t.Literal)
 	case TokenLeftParen:
 		return fmt.Sprintf("LEFT_PAREN %v null", t.Literal)
+	case TokenLeftBracket:
+		return fmt.Sprintf("LEFT_BRACKET %v null", t.Literal)
+	case TokenRightBracket:
+		return fmt.Sprintf("RIGHT_BRACKET %v null", t.Literal)
 	case TokenRightParen:
 		return fmt.Sprintf("RIGHT_PAREN %v null", t.Literal)
 	case TokenComma:
@@ -223,6 +335,14 @@ func (t Token) String() string {
 		return fmt.Sprintf("VAR %v null", t.Literal)
 	case TokenWhile:
 		return fmt.Sprintf("WHILE %v null", t.Literal)
+	case TokenTry:
+		return fmt.Sprintf("TRY %v null", t.Literal)
+	case TokenCatch:
+		return fmt.Sprintf("CATCH %v null", t.Literal)
+	case TokenFinally:
+		return fmt.Sprintf("FINALLY %v null", t.Literal)
+	case TokenThrow:
+		return fmt.Sprintf("THROW %v null", t.Literal)
 	case TokenEOF:
 		return fmt.Sprintf("EOF  null")
 	case TokenIllegal:
@@ -231,52 +351,117 @@ func (t Token) String() string {
 	return fmt.Sprintf("unknown token <%v>", t.Literal)
 }
 
+// ScanError reports a lexical error at a source line.
+type ScanError struct {
+	Line    int
+	Message string
+}
+
+func (e ScanError) Error() string {
+	return fmt.Sprintf("[line %d] Error: %s", e.Line, e.Message)
+}
+
+// Lexer turns UTF-8 encoded Lox source into tokens. It reads its input
+// incrementally, so only the token being scanned is held in memory, however
+// long the source. This bounds the memory of tokenizing alone; parsing keeps
+// every token.
 type Lexer struct {
-	input        string
-	position     int // current position in input (points to current char)
-	readPosition int // current reading position in input (after current char)
-	lineNum      int
-	ch           byte // current char under examination
+	r       *bufio.Reader
+	lineNum int
+	column  int  // column of ch in runes, counted from 1
+	ch      rune // current char under examination, 0 once done
+	next    rune // char after ch, eof or invalidUTF8
+	done    bool // ch is past the end of input, as NUL is a char like any other
+	invalid bool // the input is not valid UTF-8 and lexing has stopped
+	lexeme  strings.Builder
+	errors  []error
+	trace   *Tracer
 }
 
+const (
+	eof         rune = -1
+	invalidUTF8 rune = -2
+)
+
 func NewLexer(input string) *Lexer {
-	l := &Lexer{input: input, lineNum: 1}
+	return NewLexerFromReader(strings.NewReader(input))
+}
+
+// NewLexerFromReader returns a Lexer reading source from r. Errors reading r
+// end the input and are reported by Errors.
+func NewLexerFromReader(r io.Reader) *Lexer {
+	// no char has been read yet, so an empty input ends at column 0
+	l := &Lexer{r: bufio.NewReader(r), lineNum: 1, done: true}
+	l.next = l.readRune()
 	l.readChar()
 	return l
 }
 
-func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
-	}
-	l.position = l.readPosition
-	l.readPosition++
+// Errors returns the ScanErrors reported for the illegal tokens produced so far.
+func (l *Lexer) Errors() []error {
+	return l.errors
 }
 
-func (l *Lexer) isAtEnd() bool {
-	return l.position >= len(l.input)
+// SetTracer sends a record for every token the lexer produces to t.
+func (l *Lexer) SetTracer(t *Tracer) {
+	l.trace = t
 }
 
-func (l *Lexer) Peek() byte {
-	if l.position >= len(l.input) {
-		return byte(TokenEOF)
+func (l *Lexer) readRune() rune {
+	r, size, err := l.r.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.errors = append(l.errors, fmt.Errorf("read source: %w", err))
+		}
+		return eof
+	}
+	if r == utf8.RuneError && size == 1 {
+		return invalidUTF8
 	}
-	return l.input[l.position]
+	return r
 }
 
-func (l *Lexer) PeekNext() byte {
-	if l.readPosition >= len(l.input) {
-		return byte(TokenEOF)
+// readChar advances to the next char. At the end of input it sets done.
+func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.column = 0
+	}
+	if l.next == invalidUTF8 {
+		// nothing after a bad byte can be trusted, so stop with one error
+		l.errors = append(l.errors, ScanError{l.lineNum, "Invalid UTF-8 encoding."})
+		l.invalid = true
+		l.next = eof
 	}
-	return l.input[l.readPosition]
+	if l.next == eof {
+		if !l.done {
+			l.column++ // EOF sits just past the last char
+		}
+		l.ch, l.done = 0, true
+		return
+	}
+	l.ch, l.done = l.next, false
+	l.column++
+	l.lexeme.WriteRune(l.ch)
+	l.next = l.readRune()
+}
+
+// PeekNext returns the char after the current one, or 0 at the end of input or
+// for a NUL.
+func (l *Lexer) PeekNext() rune {
+	if l.next < 0 {
+		return 0
+	}
+	return l.next
+}
+
+func (l *Lexer) atEnd() bool {
+	return l.next < 0
 }
 
-func (l *Lexer) backup() {
-	l.readPosition = l.position
-	if l.position < len(l.input) {
-		l.ch = l.input[l.position]
+// fail records a lexical error, unless lexing stopped at invalid UTF-8.
+func (l *Lexer) fail(err error) {
+	if !l.invalid {
+		l.errors = append(l.errors, err)
 	}
 }
 
@@ -284,8 +469,16 @@ func (l *Lexer) Next() Token {
 	var tok Token
 
 	l.skipWhitespace()
+	line, column := l.lineNum, l.column
+	l.lexeme.Reset()
+	ch := l.ch
+	if l.done {
+		ch = eof
+	} else {
+		l.lexeme.WriteRune(l.ch)
+	}
 
-	switch l.ch {
+	switch ch {
 	case '(':
 		tok = Token{Type: TokenLeftParen, Literal: string(l.ch)}
 	case ')':
@@ -295,9 +488,9 @@ func (l *Lexer) Next() Token {
 	case '}':
 		tok = Token{Type: TokenRightBrace, Literal: string(l.ch)}
 	case '[':
-		tok = Token{Type: TokenLeftParen, Literal: string(l.ch)}
+		tok = Token{Type: TokenLeftBracket, Literal: string(l.ch)}
 	case ']':
-		tok = Token{Type: TokenRightParen, Literal: string(l.ch)}
+		tok = Token{Type: TokenRightBracket, Literal: string(l.ch)}
 	case ',':
 		tok = Token{Type: TokenComma, Literal: string(l.ch)}
 	case ':':
@@ -313,7 +506,6 @@ func (l *Lexer) Next() Token {
 	case '/':
 		if l.PeekNext() == '/' {
 			l.readChar() // consume the second slash
-			l.skipWhitespace()
 			tok.Type = TokenComment
 			tok.Literal = l.readComment()
 		} else {
@@ -352,23 +544,23 @@ func (l *Lexer) Next() Token {
 	case '"':
 		stringVal, err := l.readString()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v", err)
+			l.fail(err)
 			tok = Token{Type: TokenIllegal, Literal: string(l.ch)}
 		} else {
 			tok = Token{Type: TokenString, Literal: stringVal}
 		}
-	case 0:
+	case eof:
 		tok.Type = TokenEOF
 	default:
 		if isDigit(l.ch) {
 			valString, err := l.readNumber()
 			if err != nil {
-				fmt.Printf("[BOO] %v\n", err)
+				l.fail(err)
 				tok = Token{Type: TokenIllegal, Literal: string(l.ch)}
 			} else {
 				tok = Token{Type: TokenNumber, Literal: valString}
 			}
-		} else if isAlpha(l.ch) {
+		} else if isIdentStart(l.ch) {
 			tok.Literal = l.readIdentifier()
 			if v, ok := Keywords[tok.Literal]; ok {
 				tok.Type = v
@@ -376,90 +568,104 @@ func (l *Lexer) Next() Token {
 				tok.Type = TokenIdentifier
 			}
 		} else {
-			fmt.Fprintf(os.Stderr, "[line %d] Error: Unexpected character: %c\n", l.lineNum, l.ch)
+			l.fail(ScanError{l.lineNum, fmt.Sprintf("Unexpected character: %c", l.ch)})
 			tok = Token{Type: TokenIllegal, Literal: string(l.ch)}
 		}
 	}
 
+	tok.Lexeme = l.lexeme.String()
+	if tok.Type == TokenComment {
+		tok.Lexeme = strings.TrimSuffix(tok.Lexeme, "\n")
+	}
 	l.readChar()
+	tok.Line = line
+	tok.Column = column
+	if l.trace.Enabled(TraceLexer) {
+		l.trace.Log(TraceLexer, "token", "line", line, "column", column, "type", tok.Type.String(), "literal", tok.Literal)
+	}
 	return tok
 }
 
+// The read functions below leave ch on the last char of what they read.
+
 func (l *Lexer) readIdentifier() string {
-	start := l.position
-	for !l.isAtEnd() && isAlphaNumeric(l.ch) {
+	var sb strings.Builder
+	sb.WriteRune(l.ch)
+	for isIdentPart(l.PeekNext()) {
 		l.readChar()
+		sb.WriteRune(l.ch)
 	}
-	l.backup()
-	return l.input[start:l.position]
+	return sb.String()
 }
 
 func (l *Lexer) readNumber() (string, error) {
-	start := l.position
-	for isDigit(l.ch) {
-		l.readChar()
-	}
-	containsDecimal := false
-	if l.ch == '.' {
-		containsDecimal = true
+	var sb strings.Builder
+	sb.WriteRune(l.ch)
+	for isDigit(l.PeekNext()) {
 		l.readChar()
+		sb.WriteRune(l.ch)
 	}
-	foundAtleastOneDigitAfterDecimal := false
-	for isDigit(l.ch) {
-		foundAtleastOneDigitAfterDecimal = true
+	if l.PeekNext() == '.' {
 		l.readChar()
+		sb.WriteRune(l.ch)
+		if !isDigit(l.PeekNext()) {
+			// the char after the dot becomes part of the illegal token
+			l.readChar()
+			return "", ScanError{l.lineNum, "Invalid number."}
+		}
+		for isDigit(l.PeekNext()) {
+			l.readChar()
+			sb.WriteRune(l.ch)
+		}
 	}
-
-	if containsDecimal && !foundAtleastOneDigitAfterDecimal {
-		return "", errors.New("invalid number")
-	}
-
-	// we have advanced one more character ahead of the number
-	l.backup()
-
-	return l.input[start:l.position], nil
+	return sb.String(), nil
 }
 
 func (l *Lexer) readString() (string, error) {
-	start := l.position + 1
-	for l.PeekNext() != '"' && !l.isAtEnd() {
+	var sb strings.Builder
+	for l.PeekNext() != '"' && !l.atEnd() {
 		l.readChar()
 		if l.ch == '\n' {
 			l.lineNum++
 		}
+		sb.WriteRune(l.ch)
 	}
 
-	if l.isAtEnd() {
-		return "", fmt.Errorf("[line %d] Error: Unterminated string.\n", l.lineNum)
+	if l.atEnd() {
+		l.readChar()
+		return "", ScanError{l.lineNum, "Unterminated string."}
 	}
 
 	l.readChar()
 
-	return l.input[start:l.position], nil
+	return sb.String(), nil
 }
 
 func (l *Lexer) readComment() string {
-	position := l.position + 1
+	var sb strings.Builder
 	for {
 		l.readChar()
-		if l.ch == 0 || l.ch == '\n' {
+		if l.done || l.ch == '\n' {
 			l.lineNum++
 			break
 		}
+		sb.WriteRune(l.ch)
 	}
-	return l.input[position:l.position]
+	return sb.String()
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isAlpha(ch byte) bool {
-	return (ch >= 0x41 && ch <= 0x5a) || (ch >= 0x61 && ch <= 0x7a) || ch == '_'
+// isIdentStart and isIdentPart follow the ID_Start and ID_Continue classes of
+// Unicode Standard Annex #31, with '_' allowed to start an identifier.
+func isIdentStart(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.Is(unicode.Nl, ch)
 }
 
-func isAlphaNumeric(ch byte) bool {
-	return isDigit(ch) || isAlpha(ch)
+func isIdentPart(ch rune) bool {
+	return isIdentStart(ch) || unicode.In(ch, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc)
 }
 
 func (l *Lexer) skipWhitespace() {
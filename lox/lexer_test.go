@@ -0,0 +1,156 @@
+package lox_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// tokens lexes all of l, describing each token as "TYPE literal line:column".
+func tokens(l *lox.Lexer) []string {
+	var out []string
+	for {
+		tok := l.Next()
+		out = append(out, fmt.Sprintf("%v %v %v:%v", tok.Type.Name(), tok.Literal, tok.Line, tok.Column))
+		if tok.Type == lox.TokenEOF {
+			return out
+		}
+	}
+}
+
+func TestLexerUnicode(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "accented identifiers",
+			source: "var café = \"ñ\";\nprint café;",
+			want: []string{
+				"VAR var 1:1", "IDENTIFIER café 1:5", "EQUAL = 1:10", "STRING ñ 1:12", "SEMICOLON ; 1:15",
+				"PRINT print 2:1", "IDENTIFIER café 2:7", "SEMICOLON ; 2:11", "EOF  2:12",
+			},
+		},
+		{
+			name:   "non-Latin identifiers",
+			source: "変数 + Δx_1",
+			want:   []string{"IDENTIFIER 変数 1:1", "PLUS + 1:4", "IDENTIFIER Δx_1 1:6", "EOF  1:10"},
+		},
+		{
+			name:   "combining marks count as columns",
+			source: "e\u0301 = 1",
+			want:   []string{"IDENTIFIER e\u0301 1:1", "EQUAL = 1:4", "NUMBER 1 1:6", "EOF  1:7"},
+		},
+		{
+			name:   "characters outside the BMP in strings and comments",
+			source: "\"😀😀\" x // 🎉\ny",
+			want:   []string{"STRING 😀😀 1:1", "IDENTIFIER x 1:6", "COMMENT  🎉 1:8", "IDENTIFIER y 2:1", "EOF  2:2"},
+		},
+		{
+			name:   "digits in other scripts continue but do not start identifiers",
+			source: "a٣ ٣",
+			want:   []string{"IDENTIFIER a٣ 1:1", "ILLEGAL ٣ 1:4", "EOF  1:5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, l := range map[string]*lox.Lexer{
+				"string": lox.NewLexer(tt.source),
+				// runes split across reads must keep their columns
+				"one byte reads": lox.NewLexerFromReader(iotest.OneByteReader(strings.NewReader(tt.source))),
+			} {
+				if got := tokens(l); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%v:\ngot  %q\nwant %q", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLexerInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string // nil to check only the errors
+		errs   string
+	}{
+		{
+			name:   "between tokens",
+			source: "var a = 1;\nvar \xff b;",
+			want:   []string{"VAR var 1:1", "IDENTIFIER a 1:5", "EQUAL = 1:7", "NUMBER 1 1:9", "SEMICOLON ; 1:10", "VAR var 2:1", "EOF  2:5"},
+			errs:   "[line 2] Error: Invalid UTF-8 encoding.",
+		},
+		{
+			name:   "inside an identifier",
+			source: "ab\xffcd",
+			want:   []string{"IDENTIFIER ab 1:1", "EOF  1:3"},
+			errs:   "[line 1] Error: Invalid UTF-8 encoding.",
+		},
+		{
+			name:   "inside a string is the only error",
+			source: "print \"a\xff\";",
+			errs:   "[line 1] Error: Invalid UTF-8 encoding.",
+		},
+		{
+			name:   "truncated sequence",
+			source: "x \xe2\x82",
+			want:   []string{"IDENTIFIER x 1:1", "EOF  1:3"},
+			errs:   "[line 1] Error: Invalid UTF-8 encoding.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lox.NewLexer(tt.source)
+			if got := tokens(l); tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+			if got := errors.Join(l.Errors()...); got == nil || got.Error() != tt.errs {
+				t.Errorf("errors = %v, want %v", got, tt.errs)
+			}
+		})
+	}
+}
+
+func TestLexerNUL(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+		errs   string
+	}{
+		{
+			name:   "between tokens",
+			source: "a\x00b",
+			want:   []string{"IDENTIFIER a 1:1", "ILLEGAL \x00 1:2", "IDENTIFIER b 1:3", "EOF  1:4"},
+			errs:   "[line 1] Error: Unexpected character: \x00",
+		},
+		{
+			name:   "inside a string",
+			source: "\"a\x00b\" c",
+			want:   []string{"STRING a\x00b 1:1", "IDENTIFIER c 1:7", "EOF  1:8"},
+		},
+		{
+			name:   "inside a comment",
+			source: "// a\x00b\nc",
+			want:   []string{"COMMENT  a\x00b 1:1", "IDENTIFIER c 2:1", "EOF  2:2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lox.NewLexer(tt.source)
+			if got := tokens(l); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+			got := errors.Join(l.Errors()...)
+			if (got == nil) != (tt.errs == "") || got != nil && got.Error() != tt.errs {
+				t.Errorf("errors = %v, want %q", got, tt.errs)
+			}
+		})
+	}
+}
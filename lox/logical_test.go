@@ -0,0 +1,47 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"or binds looser than equality", `var a = 1; var b = 2; print a == b or "c";`, "c\n"},
+		{"and binds tighter than or", `print false and 1 or 2;`, "2\n"},
+		{"or is left associative", `print nil or false or "x";`, "x\n"},
+		{"or yields the first truthy operand", `print nil or "x";`, "x\n"},
+		{"or yields its left operand when truthy", `print 0 or "x";`, "0\n"},
+		{"and yields the first falsy operand", `print 1 and nil and 2;`, "nil\n"},
+		{"and yields its right operand", `print 1 and "x";`, "x\n"},
+		{
+			"and short-circuits",
+			`var called = false; fun sideEffect() { called = true; return true; } print false and sideEffect(); print called;`,
+			"false\nfalse\n",
+		},
+		{
+			"or short-circuits",
+			`var called = false; fun sideEffect() { called = true; return true; } print true or sideEffect(); print called;`,
+			"true\nfalse\n",
+		},
+		{"undefined right operand is not evaluated", `print false and undefined;`, "false\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := lox.New(lox.WithStdout(&out)).Run(context.Background(), tt.source); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Callable is implemented by values scripts can call.
+type Callable interface {
+	Arity() int
+	Call(e *Evaluator, args []Value) (Value, error)
+}
+
+// NativeFunction is a Callable implemented in Go.
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(e *Evaluator, args []Value) (Value, error)
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(e *Evaluator, args []Value) (Value, error) {
+	return n.fn(e, args)
+}
+
+// Name returns the name the function is bound to.
+func (n *NativeFunction) Name() string {
+	return n.name
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// LoxFunction is a function declared in a script.
+type LoxFunction struct {
+	decl    FunDeclStmt
+	closure *Environment
+}
+
+// returnValue unwinds a function body from a return statement to its call.
+type returnValue struct {
+	value Value
+}
+
+func (r returnValue) Error() string {
+	return "return outside of a function"
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.decl.Params)
+}
+
+func (f *LoxFunction) Call(e *Evaluator, args []Value) (Value, error) {
+	env := NewEnvironment(e.trace, f.closure)
+	for i, param := range f.decl.Params {
+		e.define(env, param.Literal, args[i])
+	}
+	err := e.evalBlock(f.decl.Body, env)
+	if ret, ok := err.(returnValue); ok {
+		return ret.value, nil
+	}
+	return nil, err
+}
+
+// Name returns the name the function was declared with.
+func (f *LoxFunction) Name() string {
+	return f.decl.Name.Literal
+}
+
+// Line returns the line the function is declared on.
+func (f *LoxFunction) Line() int {
+	return f.decl.Line
+}
+
+func (f *LoxFunction) String() string {
+	return fmt.Sprintf("<fn %v>", f.decl.Name.Literal)
+}
+
+func defineNatives(env *Environment) {
+	readLine := &NativeFunction{name: "readLine", arity: 0, fn: nativeReadLine}
+	env.DefineVar("readLine", readLine)
+	env.DefineVar("input", readLine)
+}
+
+// nativeReadLine returns the next line of input without its line ending, or nil
+// once the input is exhausted.
+func nativeReadLine(e *Evaluator, args []Value) (Value, error) {
+	// make sure a prompt printed by the script is visible before blocking
+	if err := e.Flush(); err != nil {
+		return nil, RuntimeError{fmt.Errorf("could not write output: %w", err), 0, false}
+	}
+	line, err := e.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, RuntimeError{fmt.Errorf("could not read input: %w", err), 0, false}
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
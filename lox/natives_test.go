@@ -0,0 +1,79 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		source string
+		want   string
+	}{
+		{"lines without their endings", "a\r\nb\n", "print readLine(); print readLine();", "a\nb\n"},
+		{"last line without a newline", "a\nb", "print readLine(); print readLine();", "a\nb\n"},
+		{"empty line", "\nx\n", `print readLine() == ""; print readLine();`, "true\nx\n"},
+		{"nil at the end of input", "a\n", "print readLine(); print readLine(); print readLine();", "a\nnil\nnil\n"},
+		{"input is readLine", "a\nb\n", "print input(); print readLine();", "a\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := run(t, tt.source, lox.WithStdin(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if out != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+// promptReader checks that the prompt was written before the script reads.
+type promptReader struct {
+	t      *testing.T
+	out    *bytes.Buffer
+	prompt string
+	done   bool
+}
+
+func (r *promptReader) Read(p []byte) (int, error) {
+	if !r.done {
+		r.done = true
+		if r.out.String() != r.prompt {
+			r.t.Errorf("output before reading = %q, want %q", r.out.String(), r.prompt)
+		}
+	}
+	return strings.NewReader("ok\n").Read(p)
+}
+
+func TestReadLineFlushesOutput(t *testing.T) {
+	var out bytes.Buffer
+	in := &promptReader{t: t, out: &out, prompt: "name?\n"}
+	if err := lox.New(lox.WithStdout(&out), lox.WithStdin(in)).Run(context.Background(), `print "name?"; print readLine();`); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out.String() != "name?\nok\n" {
+		t.Errorf("output = %q, want %q", out.String(), "name?\nok\n")
+	}
+}
+
+func TestReadLineError(t *testing.T) {
+	errBroken := errors.New("broken pipe")
+	_, err := run(t, "readLine();", lox.WithStdin(iotest.ErrReader(errBroken)))
+	var rerr lox.RuntimeError
+	if !errors.As(err, &rerr) || !errors.Is(err, errBroken) {
+		t.Errorf("Run error = %v, want a RuntimeError wrapping %v", err, errBroken)
+	}
+	if rerr.Line() != 1 {
+		t.Errorf("line = %v, want 1", rerr.Line())
+	}
+}
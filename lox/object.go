@@ -0,0 +1,239 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// HostObject exposes a Go struct, map, slice or array to scripts. Struct fields
+// and methods are reached with dot syntax, map entries with dot or bracket
+// syntax and slice elements with bracket syntax.
+//
+// A struct reached by value, such as one stored in a map or returned by a
+// function, is a copy the host never sees, so its fields and those of the
+// structs and arrays inside it cannot be assigned.
+type HostObject struct {
+	v        reflect.Value
+	allow    map[string]bool // nil allows every exported member
+	readOnly bool            // v is a copy, so writes to it would be lost
+}
+
+// BindObject exposes v to scripts as the global name. Pass a pointer to a
+// struct for scripts to be able to assign its fields. If fields is not empty,
+// only the struct fields, methods and map keys it names are accessible, on v
+// and on any object reached through it.
+func (i *Interpreter) BindObject(name string, v any, fields ...string) error {
+	var allow map[string]bool
+	if len(fields) > 0 {
+		allow = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			allow[f] = true
+		}
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !isHostKind(rv.Type()) {
+		return fmt.Errorf("bind %v: expected a struct, map, slice or array, got %T", name, v)
+	}
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fmt.Errorf("bind %v: nil %v", name, rv.Type())
+	}
+	return i.evaluator.env.DefineVar(name, newHostObject(rv, allow))
+}
+
+func newHostObject(v reflect.Value, allow map[string]bool) *HostObject {
+	if v.Kind() == reflect.Struct && !v.CanAddr() {
+		// copy into addressable memory so fields can be set and pointer
+		// methods called
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return &HostObject{v: ptr, allow: allow, readOnly: true}
+	}
+	return &HostObject{v: v, allow: allow}
+}
+
+// within returns v, reached through h, marking it read-only if it is stored by
+// value inside a read-only copy. Pointers, maps and slices in the copy still
+// share memory with the host.
+func (h *HostObject) within(v Value, err error) (Value, error) {
+	if obj, ok := v.(*HostObject); ok && h.readOnly {
+		if k := obj.v.Kind(); k == reflect.Struct || k == reflect.Array {
+			obj.readOnly = true
+		}
+	}
+	return v, err
+}
+
+func isHostKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array:
+		return true
+	case reflect.Map:
+		return isConvertible(t.Key()) && t.Key().Kind() != reflect.Interface
+	case reflect.Pointer:
+		return t.Elem().Kind() == reflect.Struct
+	}
+	return false
+}
+
+func (h *HostObject) String() string {
+	return fmt.Sprintf("%v", h.target().Interface())
+}
+
+// target is the struct, map, slice or array behind any pointer.
+func (h *HostObject) target() reflect.Value {
+	if h.v.Kind() == reflect.Pointer {
+		return h.v.Elem()
+	}
+	return h.v
+}
+
+func (h *HostObject) allowed(name string) bool {
+	return h.allow == nil || h.allow[name]
+}
+
+func (h *HostObject) getField(name string) (Value, error) {
+	if !h.allowed(name) {
+		return nil, fmt.Errorf("Undefined property '%v'.", name)
+	}
+	target := h.target()
+	switch target.Kind() {
+	case reflect.Struct:
+		if field, ok := exportedField(target, name); ok {
+			return h.within(fromGo(field, h.allow))
+		}
+	case reflect.Map:
+		if target.Type().Key().Kind() == reflect.String {
+			return h.getIndex(name)
+		}
+	case reflect.Slice, reflect.Array:
+		if name == "length" {
+			return float64(target.Len()), nil
+		}
+	}
+	if method := h.v.MethodByName(name); method.IsValid() {
+		return bindFunc(name, method, h.allow)
+	}
+	return nil, fmt.Errorf("Undefined property '%v'.", name)
+}
+
+func (h *HostObject) setField(name string, value Value) error {
+	if !h.allowed(name) {
+		return fmt.Errorf("Undefined property '%v'.", name)
+	}
+	target := h.target()
+	switch target.Kind() {
+	case reflect.Struct:
+		field, ok := exportedField(target, name)
+		if !ok {
+			return fmt.Errorf("Undefined property '%v'.", name)
+		}
+		if !field.CanSet() || h.readOnly {
+			return fmt.Errorf("Property '%v' cannot be assigned.", name)
+		}
+		v, err := toGo(value, field.Type())
+		if err != nil {
+			return fmt.Errorf("Cannot assign to '%v': %w", name, err)
+		}
+		field.Set(v)
+		return nil
+	case reflect.Map:
+		if target.Type().Key().Kind() == reflect.String {
+			return h.setIndex(name, value)
+		}
+	}
+	return fmt.Errorf("Only structs and maps have fields.")
+}
+
+func (h *HostObject) getIndex(index Value) (Value, error) {
+	target := h.target()
+	switch target.Kind() {
+	case reflect.Map:
+		key, err := h.mapKey(index)
+		if err != nil {
+			return nil, err
+		}
+		value := target.MapIndex(key)
+		if !value.IsValid() {
+			return nil, nil
+		}
+		return fromGo(value, h.allow)
+	case reflect.Slice, reflect.Array:
+		i, err := sliceIndex(index, target.Len())
+		if err != nil {
+			return nil, err
+		}
+		return h.within(fromGo(target.Index(i), h.allow))
+	}
+	return nil, fmt.Errorf("Only lists and maps can be indexed.")
+}
+
+func (h *HostObject) setIndex(index Value, value Value) error {
+	target := h.target()
+	switch target.Kind() {
+	case reflect.Map:
+		key, err := h.mapKey(index)
+		if err != nil {
+			return err
+		}
+		v, err := toGo(value, target.Type().Elem())
+		if err != nil {
+			return fmt.Errorf("Cannot assign to key %v: %w", Stringify(index), err)
+		}
+		if target.IsNil() {
+			return fmt.Errorf("Cannot assign to a nil map.")
+		}
+		target.SetMapIndex(key, v)
+		return nil
+	case reflect.Slice, reflect.Array:
+		i, err := sliceIndex(index, target.Len())
+		if err != nil {
+			return err
+		}
+		elem := target.Index(i)
+		if !elem.CanSet() || h.readOnly {
+			return fmt.Errorf("Array elements cannot be assigned.")
+		}
+		v, err := toGo(value, elem.Type())
+		if err != nil {
+			return fmt.Errorf("Cannot assign to index %v: %w", i, err)
+		}
+		elem.Set(v)
+		return nil
+	}
+	return fmt.Errorf("Only lists and maps can be indexed.")
+}
+
+func (h *HostObject) mapKey(index Value) (reflect.Value, error) {
+	if s, ok := index.(string); ok && !h.allowed(s) {
+		return reflect.Value{}, fmt.Errorf("Undefined key '%v'.", s)
+	}
+	key, err := toGo(index, h.target().Type().Key())
+	if err != nil {
+		return key, fmt.Errorf("Invalid map key: %w", err)
+	}
+	return key, nil
+}
+
+func exportedField(v reflect.Value, name string) (reflect.Value, bool) {
+	sf, ok := v.Type().FieldByName(name)
+	if !ok || !sf.IsExported() {
+		return reflect.Value{}, false
+	}
+	field, err := v.FieldByIndexErr(sf.Index)
+	if err != nil {
+		return reflect.Value{}, false
+	}
+	return field, true
+}
+
+func sliceIndex(index Value, length int) (int, error) {
+	f, ok := index.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, fmt.Errorf("Index must be an integer, got %v.", Stringify(index))
+	}
+	if f < 0 || f >= float64(length) {
+		return 0, fmt.Errorf("Index %v out of range [0, %v).", Stringify(f), length)
+	}
+	return int(f), nil
+}
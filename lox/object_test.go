@@ -0,0 +1,179 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+type Limits struct {
+	Burst int
+}
+
+type point struct {
+	X, Y int
+}
+
+type shape struct {
+	Origin  point
+	Corners [2]point
+}
+
+type server struct {
+	*Limits
+	Name   string
+	Port   int
+	Tags   []string
+	Meta   map[string]float64
+	Codes  map[int]string
+	Out    io.Writer
+	Origin point
+	Points map[string]point
+	Refs   map[string]*point
+	Shapes map[string]shape
+	secret string
+}
+
+func (s *server) Corner() point {
+	return point{1, 1}
+}
+
+func (s *server) Addr() string {
+	return fmt.Sprintf("%v:%v", s.Name, s.Port)
+}
+
+func (s *server) Greet(who string) string {
+	return "hello " + who
+}
+
+func newServer() *server {
+	return &server{
+		Name: "local", Port: 80, Tags: []string{"a", "b"}, Codes: map[int]string{200: "OK"},
+		Points: map[string]point{"a": {1, 2}},
+		Refs:   map[string]*point{"a": {1, 2}},
+		Shapes: map[string]shape{"sq": {}},
+		secret: "s",
+	}
+}
+
+func TestBindObjectErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"undefined field", "print srv.Missing;", "Undefined property 'Missing'."},
+		{"unexported field", "print srv.secret;", "Undefined property 'secret'."},
+		{"assign unexported field", `srv.secret = "x";`, "Undefined property 'secret'."},
+		{"assign method", "srv.Addr = 1;", "Undefined property 'Addr'."},
+		{"field through a nil embedded pointer", "print srv.Burst;", "Undefined property 'Burst'."},
+		{"assign wrong type", `srv.Port = "80";`, "Cannot assign to 'Port': expected integer, got string"},
+		{"assign fraction to an integer", "srv.Port = 1.5;", "Cannot assign to 'Port': 1.5 does not fit in int"},
+		{"assign a number to an interface", "srv.Out = 1;", "Cannot assign to 'Out': expected io.Writer, got number"},
+		{"assign an object lacking the methods", "srv.Out = srv;", "Cannot assign to 'Out': expected io.Writer, got *lox_test.server"},
+		{"assign field of a struct in a map", `srv.Points["a"].X = 5;`, "Property 'X' cannot be assigned."},
+		{"assign field of a returned struct", "srv.Corner().X = 5;", "Property 'X' cannot be assigned."},
+		{"assign field nested in a copy", `srv.Shapes["sq"].Origin.X = 5;`, "Property 'X' cannot be assigned."},
+		{"assign array element in a copy", `srv.Shapes["sq"].Corners[0] = srv.Origin;`, "Array elements cannot be assigned."},
+		{"method argument type", "srv.Greet(1);", "Greet: argument 1: expected string, got number"},
+		{"method arity", "srv.Greet();", "Expected 1 arguments but got 0."},
+		{"field of a slice", "srv.Tags.first = 1;", "Only structs and maps have fields."},
+		{"index a struct", "print srv[0];", "Only lists and maps can be indexed."},
+		{"index out of range", "print srv.Tags[2];", "Index 2 out of range [0, 2)."},
+		{"fractional index", "print srv.Tags[0.5];", "Index must be an integer, got 0.5."},
+		{"assign element of wrong type", "srv.Tags[0] = 1;", "Cannot assign to index 0: expected string, got number"},
+		{"map key of wrong type", `print srv.Codes["200"];`, "Invalid map key: expected integer, got string"},
+		{"field of a nil map", "srv.Meta.rate = 1;", "Only objects have fields."},
+		{"assign to a nil map", "meta.rate = 1;", "Cannot assign to a nil map."},
+		{"assign to an element of a nil map", `meta["rate"] = 1;`, "Cannot assign to a nil map."},
+		{"field not allowed", "print pub.Port;", "Undefined property 'Port'."},
+		{"method not allowed", "print pub.Addr();", "Undefined property 'Addr'."},
+		{"assign field not allowed", "pub.Port = 1;", "Undefined property 'Port'."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer()
+			interp := lox.New(lox.WithStdout(io.Discard), lox.WithStderr(io.Discard))
+			if err := interp.BindObject("srv", srv); err != nil {
+				t.Fatalf("BindObject: %v", err)
+			}
+			if err := interp.BindObject("pub", srv, "Name", "Greet"); err != nil {
+				t.Fatalf("BindObject: %v", err)
+			}
+			if err := interp.BindObject("meta", srv.Meta); err != nil {
+				t.Fatalf("BindObject: %v", err)
+			}
+			// the failing statement is on the second line
+			err := interp.Run(context.Background(), "print pub.Name;\n"+tt.source)
+			var rerr lox.RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Run error = %v, want a RuntimeError", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+			if rerr.Line() != 2 {
+				t.Errorf("line = %v, want 2", rerr.Line())
+			}
+			if !reflect.DeepEqual(srv, newServer()) {
+				t.Errorf("the failed script changed the object to %+v", srv)
+			}
+		})
+	}
+}
+
+func TestBindObjectAccess(t *testing.T) {
+	srv := newServer()
+	var out strings.Builder
+	interp := lox.New(lox.WithStdout(&out))
+	if err := interp.BindObject("srv", srv); err != nil {
+		t.Fatalf("BindObject: %v", err)
+	}
+	if err := interp.BindObject("pub", srv, "Name", "Greet"); err != nil {
+		t.Fatalf("BindObject: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := interp.BindObject("buf", &buf); err != nil {
+		t.Fatalf("BindObject: %v", err)
+	}
+	source := `srv.Out = buf;
+srv.Origin.X = 7;
+srv.Refs["a"].X = 5;
+print srv.Points["a"].X;
+srv.Port = 8080;
+srv.Tags[1] = "c";
+pub.Name = "remote";
+print srv.Addr();
+print pub.Greet(srv.Tags[1]);
+print srv.Codes[200];
+print srv.Tags.length;
+print srv.Meta;
+`
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := "1\nremote:8080\nhello c\nOK\n2\nnil\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if srv.Port != 8080 || srv.Tags[1] != "c" || srv.Name != "remote" || srv.Out != &buf ||
+		srv.Origin.X != 7 || srv.Refs["a"].X != 5 {
+		t.Errorf("the script's writes did not reach the object: %+v", srv)
+	}
+}
+
+func TestBindObjectInvalid(t *testing.T) {
+	interp := lox.New()
+	for _, v := range []any{42, "s", func() {}, nil, (*server)(nil), map[any]int{}} {
+		if err := interp.BindObject("v", v); err == nil {
+			t.Errorf("BindObject(%#v) succeeded", v)
+		}
+	}
+}
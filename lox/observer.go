@@ -0,0 +1,155 @@
+package lox
+
+import "sort"
+
+// Observer is notified as the evaluator runs a script. Debuggers, profilers and
+// coverage tools implement it and register with WithObserver. Callbacks run on
+// the evaluator's goroutine and block it until they return. Embed NopObserver
+// to implement only the callbacks you need.
+type Observer interface {
+	// StmtEnter is called before s executes and StmtExit after, with the
+	// error it raised, if any.
+	StmtEnter(e *Evaluator, s Statement)
+	StmtExit(e *Evaluator, s Statement, err error)
+	// ExprEval is called after expr has been evaluated.
+	ExprEval(e *Evaluator, expr Expression, value Value, err error)
+	// Define, Get and Assign are called when a script declares, reads or
+	// assigns a variable in env.
+	Define(e *Evaluator, env *Environment, name string, value Value)
+	Get(e *Evaluator, env *Environment, name string, value Value)
+	Assign(e *Evaluator, env *Environment, name string, value Value)
+	// CallEnter is called before fn runs and CallExit after it returns.
+	CallEnter(e *Evaluator, fn Callable, args []Value, line int)
+	CallExit(e *Evaluator, fn Callable, result Value, err error)
+	// Error is called once for each error a statement raises, by the
+	// innermost statement it escapes from, before any try catches it.
+	Error(e *Evaluator, err error)
+}
+
+// NopObserver implements every Observer callback by doing nothing.
+type NopObserver struct{}
+
+func (NopObserver) StmtEnter(*Evaluator, Statement)                {}
+func (NopObserver) StmtExit(*Evaluator, Statement, error)          {}
+func (NopObserver) ExprEval(*Evaluator, Expression, Value, error)  {}
+func (NopObserver) Define(*Evaluator, *Environment, string, Value) {}
+func (NopObserver) Get(*Evaluator, *Environment, string, Value)    {}
+func (NopObserver) Assign(*Evaluator, *Environment, string, Value) {}
+func (NopObserver) CallEnter(*Evaluator, Callable, []Value, int)   {}
+func (NopObserver) CallExit(*Evaluator, Callable, Value, error)    {}
+func (NopObserver) Error(*Evaluator, error)                        {}
+
+// BranchObserver is an Observer that also wants to know which way the script
+// branches, as coverage tools do. The evaluator calls Branch on the observers
+// that implement it.
+type BranchObserver interface {
+	Observer
+	// Branch is called when the script takes branch 0 or 1 of the and or or
+	// operator, or the try statement with a catch clause, at pos. Branch 0 is
+	// the left operand deciding the result, or the try body completing, and
+	// branch 1 the right operand being evaluated, or the catch clause running.
+	// The position of an operator is that of its token.
+	Branch(e *Evaluator, pos Pos, branch int)
+}
+
+// WithObserver registers observers with the interpreter's evaluator. It may be
+// given several times; observers are notified in registration order.
+func WithObserver(obs ...Observer) Option {
+	return func(i *Interpreter) {
+		i.observers = append(i.observers, obs...)
+	}
+}
+
+// Env returns the scope the evaluator is currently executing in.
+func (e *Evaluator) Env() *Environment {
+	return e.env
+}
+
+// Depth returns how deeply the evaluator is currently nested.
+func (e *Evaluator) Depth() int {
+	return e.depth
+}
+
+// EvalIn evaluates expr in env as if it appeared in the scope env belongs to.
+// Observers use it to inspect a script they have paused.
+func (e *Evaluator) EvalIn(env *Environment, expr Expression) (Value, error) {
+	saved := e.env
+	defer func() {
+		e.env = saved
+	}()
+	e.env = env
+	return e.EvalExpr(expr)
+}
+
+// Outer returns the enclosing scope, or nil for the global scope.
+func (e Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the names of the variables declared in this scope, but not in
+// enclosing ones, in sorted order.
+func (e Environment) Names() []string {
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the value of a variable declared in this scope, without
+// looking in enclosing ones.
+func (e Environment) Lookup(name string) (Value, bool) {
+	value, ok := e.values[name]
+	return value, ok
+}
+
+func (e *Evaluator) define(env *Environment, name string, value Value) error {
+	if err := env.DefineVar(name, value); err != nil {
+		return err
+	}
+	for _, o := range e.observers {
+		o.Define(e, env, name, value)
+	}
+	return nil
+}
+
+func (e *Evaluator) get(name string) (Value, error) {
+	value, err := e.env.GetVar(name)
+	if err == nil {
+		for _, o := range e.observers {
+			o.Get(e, e.env, name, value)
+		}
+	}
+	return value, err
+}
+
+func (e *Evaluator) assign(name string, value Value) error {
+	if err := e.env.AssignVar(name, value); err != nil {
+		return err
+	}
+	for _, o := range e.observers {
+		o.Assign(e, e.env, name, value)
+	}
+	return nil
+}
+
+func (e *Evaluator) branch(pos Pos, branch int) {
+	for _, o := range e.observers {
+		if b, ok := o.(BranchObserver); ok {
+			b.Branch(e, pos, branch)
+		}
+	}
+}
+
+// call runs fn, notifying observers around it.
+func (e *Evaluator) call(fn Callable, args []Value, line int) (Value, error) {
+	for _, o := range e.observers {
+		o.CallEnter(e, fn, args, line)
+	}
+	result, err := fn.Call(e, args)
+	for _, o := range e.observers {
+		o.CallExit(e, fn, result, err)
+	}
+	return result, err
+}
@@ -0,0 +1,63 @@
+package lox_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// errorRecorder records the errors the evaluator reports to observers.
+type errorRecorder struct {
+	lox.NopObserver
+	errs []string
+}
+
+func (r *errorRecorder) Error(e *lox.Evaluator, err error) {
+	r.errs = append(r.errs, err.Error())
+}
+
+func TestObserverErrorReportedOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name: "through nested finally blocks",
+			source: `fun f() {
+  try {
+    try { throw "x"; } finally { print 1; }
+  } finally { print 2; }
+}
+try { f(); } catch (e) { print e; }`,
+			want: []string{"uncaught exception: x"},
+		},
+		{
+			name:   "uncaught through a finally block",
+			source: `try { print -"a"; } finally { var z = 1; }`,
+			want:   []string{"Operand must be a number"},
+		},
+		{
+			name:   "rethrown from a catch clause",
+			source: `try { try { throw 1; } catch (e) { throw e; } } catch (e) {}`,
+			want:   []string{"uncaught exception: 1", "uncaught exception: 1"},
+		},
+		{
+			name: "raised again after being caught",
+			source: `fun f(n) { try { throw n; } catch (e) {} }
+f(0);
+f(1);`,
+			want: []string{"uncaught exception: 0", "uncaught exception: 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &errorRecorder{}
+			run(t, tt.source, lox.WithObserver(rec))
+			if !reflect.DeepEqual(rec.errs, tt.want) {
+				t.Errorf("reported %q, want %q", rec.errs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,628 @@
+package lox
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseError reports a syntax error at the token where parsing stopped.
+// Column is 0 when parsing stopped at the end of the input.
+type ParseError struct {
+	Line    int
+	Column  int
+	Where   string
+	Message string
+}
+
+func (e ParseError) Error() string {
+	if e.Where == "" {
+		return fmt.Sprintf("[line %d] Error at end: %s", e.Line, e.Message)
+	}
+	return fmt.Sprintf("[line %d] Error at '%s': %s", e.Line, e.Where, e.Message)
+}
+
+type Parser struct {
+	tokens   []Token
+	pos      int
+	errors   []error
+	trace    *Tracer
+	depth    int
+	maxDepth int
+	// functions counts the function bodies enclosing the current token
+	functions int
+
+	nudLookup          NudLookup
+	ledLookup          LedLookup
+	statementLookup    StatementLookup
+	bindingPowerLookup BindingPowerLookup
+}
+
+func NewParser(tokens []Token, trace *Tracer) *Parser {
+	// comments carry no meaning for the parser
+	significant := make([]Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Type != TokenComment {
+			significant = append(significant, tok)
+		}
+	}
+	p := &Parser{
+		tokens:   significant,
+		pos:      0,
+		trace:    trace,
+		maxDepth: DefaultMaxDepth,
+	}
+	p.initLookups()
+	return p
+}
+
+// Parse parses the tokens as a program, stopping at the first syntax error.
+func (p *Parser) Parse() (block BlockStmt, err error) {
+	defer p.recover(&err)
+	p.trace.Log(TraceParser, "BEGIN Parse")
+	body := make([]Statement, 0)
+	for p.hasNext() {
+		body = append(body, parseStatement(p))
+	}
+	if p.trace.Enabled(TraceParser) {
+		p.trace.Log(TraceParser, "END Parse", "statements", len(body))
+	}
+	return BlockStmt{Pos: Pos{Line: 1, Column: 1}, Body: body}, nil
+}
+
+// ParseExpression parses the tokens as a single expression.
+func (p *Parser) ParseExpression() (expr Expression, err error) {
+	defer p.recover(&err)
+	expr = parseExpression(p, Lowest)
+	if p.hasNext() {
+		p.fail(p.current(), "Expect end of expression.")
+	}
+	return expr, nil
+}
+
+// fail aborts parsing with a ParseError at tok. It unwinds to the recover in
+// Parse or ParseExpression so that parse functions need not return errors.
+func (p *Parser) fail(tok Token, message string) {
+	err := ParseError{Line: tok.Line, Column: tok.Column, Where: tok.Literal, Message: message}
+	if tok.Type == TokenEOF {
+		err.Line = p.lastLine()
+		err.Column = 0
+		err.Where = ""
+	}
+	p.errors = append(p.errors, err)
+	if p.trace.Enabled(TraceParser) {
+		p.trace.Log(TraceParser, "parse error", "error", err)
+	}
+	panic(err)
+}
+
+func (p *Parser) recover(err *error) {
+	if r := recover(); r != nil {
+		parseErr, ok := r.(ParseError)
+		if !ok {
+			panic(r)
+		}
+		*err = parseErr
+	}
+}
+
+func (p *Parser) lastLine() int {
+	if len(p.tokens) == 0 {
+		return 1
+	}
+	for i := len(p.tokens) - 1; i >= 0; i-- {
+		if p.tokens[i].Line > 0 {
+			return p.tokens[i].Line
+		}
+	}
+	return 1
+}
+
+func (p *Parser) advance() Token {
+	tok := p.current()
+	p.pos++
+	return tok
+}
+
+func (p *Parser) expect(tokenType TokenType) Token {
+	if p.current().Type != tokenType {
+		p.fail(p.current(), fmt.Sprintf("Expect '%v'.", tokenType))
+	}
+	return p.advance()
+}
+
+// nest tracks recursion into nested expressions and blocks so hostile input
+// is rejected before it can exhaust the stack.
+func (p *Parser) nest() {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		p.fail(p.current(), fmt.Sprintf("Nesting exceeds maximum depth of %v.", p.maxDepth))
+	}
+}
+
+func (p *Parser) unnest() {
+	p.depth--
+}
+
+func (p *Parser) current() Token {
+	if p.pos >= len(p.tokens) {
+		return Token{
+			Type: TokenEOF,
+		}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *Parser) nextTokenBindingPower() BindingPower {
+	if p.pos >= len(p.tokens) {
+		return Lowest
+	}
+	return p.bindingPowerLookup[p.tokens[p.pos].Type]
+}
+
+func (p *Parser) hasNext() bool {
+	return p.pos < len(p.tokens) && p.current().Type != TokenEOF
+}
+
+type BindingPower int
+
+const (
+	Lowest BindingPower = iota
+	Comma
+	Assignment
+	Or
+	And
+	Logical
+	Relational
+	Additive
+	Multiplicative
+	Unary
+	Call
+	Member
+	Group
+	Primary
+)
+
+var bindingPowerStr = map[BindingPower]string{
+	Lowest:         "Lowest",
+	Comma:          "Comma",
+	Assignment:     "Assignment",
+	Or:             "Or",
+	And:            "And",
+	Logical:        "Logical",
+	Relational:     "Relational",
+	Additive:       "Additive",
+	Multiplicative: "Multiplicative",
+	Unary:          "Unary",
+	Call:           "Call",
+	Member:         "Member",
+	Group:          "Group",
+	Primary:        "Primary",
+}
+
+func (bp BindingPower) String() string {
+	return fmt.Sprintf("%s(%d)", bindingPowerStr[bp], bp)
+}
+
+type StatementHandler func(p *Parser) Statement
+type NudHandler func(p *Parser) Expression
+type LedHandler func(p *Parser, left Expression, bp BindingPower) Expression
+
+type StatementLookup map[TokenType]StatementHandler
+type NudLookup map[TokenType]NudHandler
+type LedLookup map[TokenType]LedHandler
+type BindingPowerLookup map[TokenType]BindingPower
+
+func (p *Parser) nud(tokenType TokenType, nudFn NudHandler) {
+	p.nudLookup[tokenType] = nudFn
+}
+
+func (p *Parser) led(tokenType TokenType, ledFn LedHandler) {
+	p.ledLookup[tokenType] = ledFn
+}
+
+// initLookups builds the parser's own tables, so parsers running on separate
+// goroutines share no mutable state.
+func (p *Parser) initLookups() {
+	p.nudLookup = NudLookup{}
+	p.ledLookup = LedLookup{}
+	p.bindingPowerLookup = BindingPowerLookup{
+		TokenEOF:          Lowest,
+		TokenRightParen:   Lowest,
+		TokenEqual:        Assignment,
+		TokenOr:           Or,
+		TokenAnd:          And,
+		TokenBangEqual:    Logical,
+		TokenEqualEqual:   Logical,
+		TokenTrue:         Logical,
+		TokenFalse:        Logical,
+		TokenLess:         Relational,
+		TokenLessEqual:    Relational,
+		TokenGreater:      Relational,
+		TokenGreaterEqual: Relational,
+		TokenPlus:         Additive,
+		TokenMinus:        Additive,
+		TokenStar:         Multiplicative,
+		TokenSlash:        Multiplicative,
+		TokenBang:         Unary,
+		TokenLeftParen:    Call,
+		TokenDot:          Member,
+		TokenLeftBracket:  Member,
+		TokenNumber:       Primary,
+		TokenString:       Primary,
+		TokenIdentifier:   Primary,
+	}
+
+	p.led(TokenAnd, parseBinaryExpr)
+	p.led(TokenOr, parseBinaryExpr)
+	p.led(TokenBangEqual, parseBinaryExpr)
+	p.led(TokenEqualEqual, parseBinaryExpr)
+	p.led(TokenEqual, parseAssignmentExpr)
+
+	p.led(TokenLess, parseBinaryExpr)
+	p.led(TokenLessEqual, parseBinaryExpr)
+	p.led(TokenGreater, parseBinaryExpr)
+	p.led(TokenGreaterEqual, parseBinaryExpr)
+
+	p.led(TokenPlus, parseBinaryExpr)
+	p.led(TokenMinus, parseBinaryExpr)
+	p.led(TokenStar, parseBinaryExpr)
+	p.led(TokenSlash, parseBinaryExpr)
+	p.led(TokenDot, parseGetExpr)
+	p.led(TokenLeftParen, parseCallExpr)
+	p.led(TokenLeftBracket, parseIndexExpr)
+
+	p.nud(TokenNumber, parsePrimaryExpr)
+	p.nud(TokenString, parsePrimaryExpr)
+	p.nud(TokenIdentifier, parsePrimaryExpr)
+	p.nud(TokenTrue, parsePrimaryExpr)
+	p.nud(TokenFalse, parsePrimaryExpr)
+	p.nud(TokenLeftParen, parseGroupExpr)
+	p.nud(TokenNil, parsePrimaryExpr)
+	p.nud(TokenMinus, parseUnaryExpr)
+	p.nud(TokenBang, parseUnaryExpr)
+	p.statementLookup = StatementLookup{
+		TokenPrint:     parsePrintStmt,
+		TokenVar:       parseVarDeclStmt,
+		TokenLeftBrace: parseBlockStmt,
+		TokenThrow:     parseThrowStmt,
+		TokenTry:       parseTryStmt,
+		TokenFun:       parseFunDeclStmt,
+		TokenReturn:    parseReturnStmt,
+	}
+}
+
+func parseExpression(p *Parser, bp BindingPower) Expression {
+	p.trace.Log(TraceParser, "BEGIN parseExpression")
+	p.nest()
+	defer p.unnest()
+	token := p.current()
+	tokenType := token.Type
+	nudFn, ok := p.nudLookup[tokenType]
+	if !ok {
+		p.fail(token, "Expect expression.")
+	}
+
+	left := nudFn(p)
+
+	// Parse prefix
+	for p.hasNext() && p.nextTokenBindingPower() > bp {
+		nextTokenType := p.current().Type
+		if nextTokenType == TokenRightParen || nextTokenType == TokenSemiColon {
+			// End of a group expression
+			if p.trace.Enabled(TraceParser) {
+				p.trace.Log(TraceParser, "END parseExpression", "bp", bp)
+			}
+			return left
+		}
+		ledFn, ok := p.ledLookup[nextTokenType]
+		if !ok {
+			p.fail(p.current(), "Unexpected token.")
+		}
+		left = ledFn(p, left, p.nextTokenBindingPower())
+	}
+
+	p.trace.Log(TraceParser, "END parseExpression")
+	return left
+}
+
+func parseGroupExpr(p *Parser) Expression {
+	// skip past the open paren
+	open := p.advance()
+
+	// parse the contained expression
+	expr := GroupExpr{
+		Pos:        open.Position(),
+		Expression: parseExpression(p, Lowest),
+	}
+
+	// consume the closing paren
+	p.expect(TokenRightParen)
+	return expr
+}
+
+func parseUnaryExpr(p *Parser) Expression {
+	op := p.advance()
+	return UnaryExpr{
+		Pos:     op.Position(),
+		Op:      op,
+		Operand: parseExpression(p, Unary),
+	}
+}
+
+func parseBinaryExpr(p *Parser, left Expression, bp BindingPower) Expression {
+	op := p.advance()
+	right := parseExpression(p, p.bindingPowerLookup[op.Type])
+	return BinaryExpr{
+		Pos:   left.Position(),
+		Left:  left,
+		Op:    op,
+		Right: right,
+	}
+}
+
+func parseAssignmentExpr(p *Parser, left Expression, bp BindingPower) Expression {
+	// Assignment operator
+	equals := p.advance()
+	switch target := left.(type) {
+	case IdentifierExpr:
+		return AssignmentExpr{
+			Pos:        target.Pos,
+			Identifier: Token{Literal: target.Value, Type: TokenIdentifier, Line: target.Line, Column: target.Column},
+			Value:      parseExpression(p, Lowest),
+		}
+	case GetExpr:
+		return SetExpr{
+			Pos:    target.Pos,
+			Object: target.Object,
+			Name:   target.Name,
+			Value:  parseExpression(p, Lowest),
+		}
+	case IndexExpr:
+		return SetIndexExpr{
+			Pos:     target.Pos,
+			Object:  target.Object,
+			Bracket: target.Bracket,
+			Index:   target.Index,
+			Value:   parseExpression(p, Lowest),
+		}
+	}
+	p.fail(equals, "Invalid assignment target.")
+	return nil
+}
+
+func parseIndexExpr(p *Parser, left Expression, bp BindingPower) Expression {
+	bracket := p.advance()
+	index := parseExpression(p, Lowest)
+	p.expect(TokenRightBracket)
+	return IndexExpr{
+		Pos:     left.Position(),
+		Object:  left,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+func parseCallExpr(p *Parser, left Expression, bp BindingPower) Expression {
+	paren := p.advance()
+	args := make([]Expression, 0)
+	for p.hasNext() && p.current().Type != TokenRightParen {
+		args = append(args, parseExpression(p, Lowest))
+		if p.current().Type != TokenComma {
+			break
+		}
+		p.advance()
+	}
+	p.expect(TokenRightParen)
+	return CallExpr{
+		Pos:    left.Position(),
+		Callee: left,
+		Paren:  paren,
+		Args:   args,
+	}
+}
+
+func parseGetExpr(p *Parser, left Expression, bp BindingPower) Expression {
+	// skip past the dot
+	p.advance()
+	name := p.advance()
+	if name.Type != TokenIdentifier {
+		p.fail(name, "Expect property name after '.'.")
+	}
+	return GetExpr{
+		Pos:    left.Position(),
+		Object: left,
+		Name:   name,
+	}
+}
+
+func parsePrimaryExpr(p *Parser) Expression {
+	pos := p.current().Position()
+	currentTokenType := p.current().Type
+	switch currentTokenType {
+	case TokenNumber:
+		num, _ := strconv.ParseFloat(p.advance().Literal, 64)
+		return NumberExpr{
+			Pos:   pos,
+			Value: num,
+		}
+	case TokenString:
+		return StringExpr{
+			Pos:   pos,
+			Value: p.advance().Literal,
+		}
+	case TokenIdentifier:
+		return IdentifierExpr{
+			Pos:   pos,
+			Value: p.advance().Literal,
+		}
+	case TokenTrue, TokenFalse:
+		return BoolExpr{
+			Pos:   pos,
+			Value: p.advance().Literal == "true",
+		}
+	case TokenNil:
+		_ = p.advance()
+		return NilExpr{Pos: pos}
+	default:
+		p.fail(p.current(), "Expect expression.")
+		return nil
+	}
+}
+
+func parseStatement(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseStatement")
+	p.nest()
+	defer p.unnest()
+	tokenType := p.current().Type
+	stmtFn, ok := p.statementLookup[tokenType]
+	if ok {
+		stmt := stmtFn(p)
+		p.trace.Log(TraceParser, "END parseStatement")
+		return stmt
+	}
+
+	expr := parseExpression(p, Lowest)
+	p.trace.Log(TraceParser, "END parseStatement")
+	if p.current().Type == TokenSemiColon {
+		p.advance()
+	}
+	return ExpressionStmt{
+		Pos:        expr.Position(),
+		Expression: expr,
+	}
+}
+
+func parsePrintStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parsePrintStmt")
+	// print keyword
+	keyword := p.expect(TokenPrint)
+
+	expr := parseExpression(p, Lowest)
+	p.expect(TokenSemiColon)
+	p.trace.Log(TraceParser, "END parsePrintStmt")
+	return PrintStmt{Pos: keyword.Position(), Expression: expr}
+}
+
+func parseVarDeclStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseVarDeclStmt")
+	// var keyword
+	keyword := p.expect(TokenVar)
+
+	varName := p.advance()
+	if varName.Type != TokenIdentifier {
+		p.fail(varName, "Expect variable name.")
+	}
+	var expr Expression = NilExpr{Pos: varName.Position()}
+	if p.current().Type == TokenEqual {
+		p.advance()
+		expr = parseExpression(p, Lowest)
+	}
+	p.trace.Log(TraceParser, "END parseVarDeclStmt")
+	p.expect(TokenSemiColon)
+	return VarDeclStmt{
+		Pos:        keyword.Position(),
+		Name:       varName,
+		Expression: expr,
+	}
+
+}
+
+func parseExpressionStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseExpressionStmt")
+	expr := parseExpression(p, Lowest)
+	p.trace.Log(TraceParser, "END parseExpressionStmt")
+	p.expect(TokenSemiColon)
+	return ExpressionStmt{Pos: expr.Position(), Expression: expr}
+}
+
+func parseBlockStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseBlockStmt")
+	body := make([]Statement, 0)
+	open := p.expect(TokenLeftBrace)
+	for p.hasNext() && p.current().Type != TokenRightBrace {
+		body = append(body, parseStatement(p))
+	}
+	p.trace.Log(TraceParser, "END parseBlockStmt")
+	p.expect(TokenRightBrace)
+	return BlockStmt{Pos: open.Position(), Body: body}
+}
+
+func parseThrowStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseThrowStmt")
+	throw := p.advance()
+	expr := parseExpression(p, Lowest)
+	p.expect(TokenSemiColon)
+	p.trace.Log(TraceParser, "END parseThrowStmt")
+	return ThrowStmt{Pos: throw.Position(), Expression: expr}
+}
+
+func parseTryStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseTryStmt")
+	try := p.expect(TokenTry)
+	stmt := TryStmt{Pos: try.Position(), Body: parseBlockStmt(p).(BlockStmt)}
+	if p.current().Type == TokenCatch {
+		p.advance()
+		p.expect(TokenLeftParen)
+		name := p.advance()
+		if name.Type != TokenIdentifier {
+			p.fail(name, "Expect catch variable name.")
+		}
+		p.expect(TokenRightParen)
+		catch := parseBlockStmt(p).(BlockStmt)
+		stmt.CatchName = name
+		stmt.Catch = &catch
+	}
+	if p.current().Type == TokenFinally {
+		p.advance()
+		finally := parseBlockStmt(p).(BlockStmt)
+		stmt.Finally = &finally
+	}
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.fail(p.current(), "Expect 'catch' or 'finally' after try block.")
+	}
+	p.trace.Log(TraceParser, "END parseTryStmt")
+	return stmt
+}
+
+func parseFunDeclStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseFunDeclStmt")
+	fun := p.expect(TokenFun)
+	name := p.advance()
+	if name.Type != TokenIdentifier {
+		p.fail(name, "Expect function name.")
+	}
+	p.expect(TokenLeftParen)
+	params := make([]Token, 0)
+	for p.current().Type != TokenRightParen {
+		param := p.advance()
+		if param.Type != TokenIdentifier {
+			p.fail(param, "Expect parameter name.")
+		}
+		params = append(params, param)
+		if p.current().Type != TokenComma {
+			break
+		}
+		p.advance()
+	}
+	p.expect(TokenRightParen)
+	p.functions++
+	body := parseBlockStmt(p).(BlockStmt)
+	p.functions--
+	p.trace.Log(TraceParser, "END parseFunDeclStmt")
+	return FunDeclStmt{Pos: fun.Position(), Name: name, Params: params, Body: body.Body}
+}
+
+func parseReturnStmt(p *Parser) Statement {
+	p.trace.Log(TraceParser, "BEGIN parseReturnStmt")
+	keyword := p.advance()
+	if p.functions == 0 {
+		p.fail(keyword, "Can't return from top-level code.")
+	}
+	stmt := ReturnStmt{Pos: keyword.Position(), Keyword: keyword}
+	if p.current().Type != TokenSemiColon {
+		stmt.Value = parseExpression(p, Lowest)
+	}
+	p.expect(TokenSemiColon)
+	p.trace.Log(TraceParser, "END parseReturnStmt")
+	return stmt
+}
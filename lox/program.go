@@ -0,0 +1,33 @@
+package lox
+
+import (
+	"io"
+	"strings"
+)
+
+// Program is a parsed script. Nothing modifies a Program once it is compiled,
+// so one Program can be run by any number of Interpreters at the same time,
+// each in its own environment.
+type Program struct {
+	block BlockStmt
+}
+
+// Compile parses source into a Program. Only the options that affect parsing,
+// such as WithTracer and WithMaxDepth, are used.
+func Compile(source string, opts ...Option) (*Program, error) {
+	return CompileReader(strings.NewReader(source), opts...)
+}
+
+// CompileReader is like Compile but reads the source from r. Like the tree
+// it returns, its memory use grows with the length of the source.
+func CompileReader(r io.Reader, opts ...Option) (*Program, error) {
+	block, err := configure(opts).ParseReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{block: block}, nil
+}
+
+func (p *Program) String() string {
+	return p.block.String()
+}
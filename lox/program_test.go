@@ -0,0 +1,61 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+// TestProgramConcurrentExec runs one compiled Program in many interpreters at
+// once while others parse, so that go test -race catches shared state.
+func TestProgramConcurrentExec(t *testing.T) {
+	prog, err := lox.Compile(`
+fun twice(n) {
+  var doubled = n * 2;
+  return doubled;
+}
+var total = 0;
+try {
+  total = twice(twice(total + 1));
+} catch (e) {
+  total = -1;
+}
+print total;
+`)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var out bytes.Buffer
+			if err := lox.New(lox.WithStdout(&out)).Exec(context.Background(), prog); err != nil {
+				errs <- err
+				return
+			}
+			if out.String() != "4\n" {
+				errs <- fmt.Errorf("output = %q, want %q", out.String(), "4\n")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			source := fmt.Sprintf("var x%v = (1 + %v) * 2 == 4;", i, i)
+			if _, err := lox.New().Parse(source); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
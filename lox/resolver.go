@@ -0,0 +1,337 @@
+package lox
+
+import "fmt"
+
+// SymbolKind tells what declared a Symbol.
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolFunction
+	SymbolParameter
+	SymbolCatchVariable
+	SymbolBuiltin // defined by the host before the program runs
+)
+
+var symbolKindNames = map[SymbolKind]string{
+	SymbolVariable:      "variable",
+	SymbolFunction:      "function",
+	SymbolParameter:     "parameter",
+	SymbolCatchVariable: "catch variable",
+	SymbolBuiltin:       "builtin",
+}
+
+func (k SymbolKind) String() string {
+	return symbolKindNames[k]
+}
+
+// Symbol is a name declared by a program or defined by its host.
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	// Pos is the position of the name in its declaration and Decl that of the
+	// declaring statement. Both are zero for builtins.
+	Pos    Pos
+	Decl   Pos
+	Global bool
+	// Function is the function whose body declares the symbol, or nil.
+	Function *Symbol
+	// Params are the parameter names of a function.
+	Params []string
+	// Refs are the positions of the uses of the name, in source order. A
+	// global declared again adds the later declaration to its Refs.
+	Refs []Pos
+}
+
+// ResolveError reports a use of a name that is never declared, or a
+// declaration that is likely a mistake. Warnings do not stop a program from
+// running.
+type ResolveError struct {
+	Pos
+	Name    string
+	Message string
+	Warning bool
+}
+
+func (e ResolveError) Error() string {
+	if e.Warning {
+		return fmt.Sprintf("[line %d] Warning at '%s': %s", e.Line, e.Name, e.Message)
+	}
+	return fmt.Sprintf("[line %d] Error at '%s': %s", e.Line, e.Name, e.Message)
+}
+
+// Resolution binds the names used by a program to their declarations.
+type Resolution struct {
+	// Symbols holds the builtins followed by the program's declarations in
+	// source order.
+	Symbols []*Symbol
+	Errors  []ResolveError
+	at      map[Pos]*Symbol
+}
+
+// SymbolAt returns the symbol whose name is declared or used at pos, or nil.
+func (r *Resolution) SymbolAt(pos Pos) *Symbol {
+	return r.at[pos]
+}
+
+// Resolve binds each name in block to its declaration, following the scoping
+// of the evaluator: the top level declares globals, blocks, function bodies
+// and catch clauses declare locals, and functions see globals declared after
+// them. builtins names the globals the host defines, such as those returned
+// by Interpreter.Globals.
+func Resolve(block BlockStmt, builtins []string) *Resolution {
+	r := &resolver{
+		res:      &Resolution{at: make(map[Pos]*Symbol)},
+		globals:  make(map[string]*Symbol),
+		declared: make(map[*Symbol]bool),
+	}
+	for _, name := range builtins {
+		sym := &Symbol{Name: name, Kind: SymbolBuiltin, Global: true}
+		r.res.Symbols = append(r.res.Symbols, sym)
+		r.globals[name] = sym
+		r.declared[sym] = true
+	}
+	// functions may use globals declared after them, so find those first
+	for _, s := range block.Body {
+		var name Token
+		kind := SymbolVariable
+		switch s := s.(type) {
+		case VarDeclStmt:
+			name = s.Name
+		case FunDeclStmt:
+			name, kind = s.Name, SymbolFunction
+		default:
+			continue
+		}
+		if sym, ok := r.globals[name.Literal]; !ok || sym.Kind == SymbolBuiltin {
+			r.globals[name.Literal] = &Symbol{Name: name.Literal, Kind: kind, Pos: name.Position(), Decl: s.Position(), Global: true}
+		}
+	}
+	for _, s := range block.Body {
+		s.accept(r)
+	}
+	return r.res
+}
+
+type resolver struct {
+	res *Resolution
+	// scopes are the local scopes, innermost last
+	scopes  []map[string]*Symbol
+	globals map[string]*Symbol
+	// declared holds the globals whose declaration has been resolved
+	declared map[*Symbol]bool
+	function *Symbol
+}
+
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*Symbol))
+}
+
+func (r *resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// declare records the declaration of name by the statement at decl.
+func (r *resolver) declare(name Token, kind SymbolKind, decl Pos) *Symbol {
+	if len(r.scopes) == 0 {
+		sym := r.globals[name.Literal]
+		if r.declared[sym] {
+			// declaring a global again assigns it
+			r.use(sym, name.Position())
+			return sym
+		}
+		r.declared[sym] = true
+		r.res.Symbols = append(r.res.Symbols, sym)
+		r.res.at[sym.Pos] = sym
+		return sym
+	}
+	sym := &Symbol{
+		Name:     name.Literal,
+		Kind:     kind,
+		Pos:      name.Position(),
+		Decl:     decl,
+		Function: r.function,
+	}
+	r.res.Symbols = append(r.res.Symbols, sym)
+	r.res.at[sym.Pos] = sym
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[sym.Name]; ok {
+		r.res.Errors = append(r.res.Errors, ResolveError{sym.Pos, sym.Name, "Already a variable with this name in this scope.", true})
+	}
+	scope[sym.Name] = sym
+	return sym
+}
+
+// lookup records a use of name at pos.
+func (r *resolver) lookup(name string, pos Pos) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if sym, ok := r.scopes[i][name]; ok {
+			r.use(sym, pos)
+			return
+		}
+	}
+	// functions run after the globals declared below them, top level code
+	// only after those declared above it
+	if sym, ok := r.globals[name]; ok && (r.function != nil || r.declared[sym]) {
+		r.use(sym, pos)
+		return
+	}
+	r.res.Errors = append(r.res.Errors, ResolveError{pos, name, fmt.Sprintf("Undefined variable '%v'.", name), false})
+}
+
+func (r *resolver) use(sym *Symbol, pos Pos) {
+	sym.Refs = append(sym.Refs, pos)
+	r.res.at[pos] = sym
+}
+
+func (r *resolver) resolveExpr(expr Expression) {
+	if expr != nil {
+		expr.accept(r)
+	}
+}
+
+func (r *resolver) resolveBody(body []Statement) {
+	for _, s := range body {
+		s.accept(r)
+	}
+}
+
+func (r *resolver) VisitNumberExpr(n NumberExpr) (float64, error) {
+	return 0, nil
+}
+
+func (r *resolver) VisitStringExpr(s StringExpr) (string, error) {
+	return "", nil
+}
+
+func (r *resolver) VisitUnaryExpr(u UnaryExpr) (any, error) {
+	r.resolveExpr(u.Operand)
+	return nil, nil
+}
+
+func (r *resolver) VisitBinaryExpr(b BinaryExpr) (any, error) {
+	r.resolveExpr(b.Left)
+	r.resolveExpr(b.Right)
+	return nil, nil
+}
+
+func (r *resolver) VisitBoolExpr(b BoolExpr) (any, error) {
+	return nil, nil
+}
+
+func (r *resolver) VisitIdentifierExpr(i IdentifierExpr) (any, error) {
+	r.lookup(i.Value, i.Pos)
+	return nil, nil
+}
+
+func (r *resolver) VisitGroupExpr(g GroupExpr) (any, error) {
+	r.resolveExpr(g.Expression)
+	return nil, nil
+}
+
+func (r *resolver) VisitNilExpr(n NilExpr) (any, error) {
+	return nil, nil
+}
+
+func (r *resolver) VisitAssignmentExpr(a AssignmentExpr) (any, error) {
+	r.lookup(a.Identifier.Literal, a.Identifier.Position())
+	r.resolveExpr(a.Value)
+	return nil, nil
+}
+
+func (r *resolver) VisitGetExpr(g GetExpr) (any, error) {
+	r.resolveExpr(g.Object)
+	return nil, nil
+}
+
+func (r *resolver) VisitCallExpr(c CallExpr) (any, error) {
+	r.resolveExpr(c.Callee)
+	for _, arg := range c.Args {
+		r.resolveExpr(arg)
+	}
+	return nil, nil
+}
+
+func (r *resolver) VisitSetExpr(s SetExpr) (any, error) {
+	r.resolveExpr(s.Object)
+	r.resolveExpr(s.Value)
+	return nil, nil
+}
+
+func (r *resolver) VisitIndexExpr(i IndexExpr) (any, error) {
+	r.resolveExpr(i.Object)
+	r.resolveExpr(i.Index)
+	return nil, nil
+}
+
+func (r *resolver) VisitSetIndexExpr(s SetIndexExpr) (any, error) {
+	r.resolveExpr(s.Object)
+	r.resolveExpr(s.Index)
+	r.resolveExpr(s.Value)
+	return nil, nil
+}
+
+func (r *resolver) VisitPrintStmt(p PrintStmt) error {
+	r.resolveExpr(p.Expression)
+	return nil
+}
+
+func (r *resolver) VisitExpressionStmt(s ExpressionStmt) error {
+	r.resolveExpr(s.Expression)
+	return nil
+}
+
+func (r *resolver) VisitVarDeclStmt(v VarDeclStmt) error {
+	// the initializer runs before the name is defined
+	r.resolveExpr(v.Expression)
+	r.declare(v.Name, SymbolVariable, v.Pos)
+	return nil
+}
+
+func (r *resolver) VisitBlockStmt(b BlockStmt) error {
+	r.beginScope()
+	r.resolveBody(b.Body)
+	r.endScope()
+	return nil
+}
+
+func (r *resolver) VisitThrowStmt(t ThrowStmt) error {
+	r.resolveExpr(t.Expression)
+	return nil
+}
+
+func (r *resolver) VisitTryStmt(t TryStmt) error {
+	r.VisitBlockStmt(t.Body)
+	if t.Catch != nil {
+		r.beginScope()
+		r.declare(t.CatchName, SymbolCatchVariable, t.CatchName.Position())
+		r.resolveBody(t.Catch.Body)
+		r.endScope()
+	}
+	if t.Finally != nil {
+		r.VisitBlockStmt(*t.Finally)
+	}
+	return nil
+}
+
+func (r *resolver) VisitFunDeclStmt(f FunDeclStmt) error {
+	// declared before its body so that it can call itself
+	fn := r.declare(f.Name, SymbolFunction, f.Pos)
+	fn.Params = make([]string, len(f.Params))
+	enclosing := r.function
+	r.function = fn
+	r.beginScope()
+	for i, param := range f.Params {
+		fn.Params[i] = param.Literal
+		r.declare(param, SymbolParameter, param.Position())
+	}
+	r.resolveBody(f.Body)
+	r.endScope()
+	r.function = enclosing
+	return nil
+}
+
+func (r *resolver) VisitReturnStmt(s ReturnStmt) error {
+	r.resolveExpr(s.Value)
+	return nil
+}
@@ -0,0 +1,111 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SnapshotVersion is the version of the format written by Snapshot. Restore
+// rejects snapshots written in any other version.
+const SnapshotVersion = 1
+
+type snapshot struct {
+	Version int              `json:"version"`
+	Globals []snapshotGlobal `json:"globals"`
+}
+
+type snapshotGlobal struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Number  *float64 `json:"number,omitempty"`
+	String  *string  `json:"string,omitempty"`
+	Boolean *bool    `json:"boolean,omitempty"`
+	Line    int      `json:"line,omitempty"`
+}
+
+// Snapshot writes the interpreter's global variables to w so that Restore can
+// recreate them in another Interpreter. Native functions are left out, since
+// every Interpreter defines its own, and so are functions declared by the
+// script, whose closures cannot be saved; Snapshot returns the names of the
+// latter. Host objects cannot be saved and make Snapshot fail.
+func (i *Interpreter) Snapshot(w io.Writer) (skipped []string, err error) {
+	snap := snapshot{Version: SnapshotVersion, Globals: make([]snapshotGlobal, 0)}
+	for _, name := range i.Globals() {
+		value, _ := i.Global(name)
+		global := snapshotGlobal{Name: name}
+		switch v := value.(type) {
+		case nil:
+			global.Type = "nil"
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("snapshot %v: cannot save non-finite number %v", name, v)
+			}
+			global.Type = "number"
+			global.Number = &v
+		case string:
+			global.Type = "string"
+			global.String = &v
+		case bool:
+			global.Type = "boolean"
+			global.Boolean = &v
+		case ErrorValue:
+			global.Type = "error"
+			global.String = &v.Message
+			global.Line = v.Line
+		case *NativeFunction:
+			continue
+		case *LoxFunction:
+			skipped = append(skipped, name)
+			continue
+		case *HostObject:
+			return nil, fmt.Errorf("snapshot %v: cannot save host object of type %v", name, v.v.Type())
+		default:
+			return nil, fmt.Errorf("snapshot %v: cannot save value of type %T", name, value)
+		}
+		snap.Globals = append(snap.Globals, global)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return nil, err
+	}
+	return skipped, nil
+}
+
+// Restore defines the globals saved by Snapshot, replacing any existing
+// globals with the same names.
+func (i *Interpreter) Restore(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if snap.Version != SnapshotVersion {
+		return fmt.Errorf("restore: unsupported snapshot version %v, want %v", snap.Version, SnapshotVersion)
+	}
+	values := make(map[string]Value, len(snap.Globals))
+	for _, global := range snap.Globals {
+		var value Value
+		switch {
+		case global.Type == "nil":
+			value = nil
+		case global.Type == "number" && global.Number != nil:
+			value = *global.Number
+		case global.Type == "string" && global.String != nil:
+			value = *global.String
+		case global.Type == "boolean" && global.Boolean != nil:
+			value = *global.Boolean
+		case global.Type == "error" && global.String != nil:
+			value = ErrorValue{Message: *global.String, Line: global.Line}
+		default:
+			return fmt.Errorf("restore %v: invalid value of type %q", global.Name, global.Type)
+		}
+		values[global.Name] = value
+	}
+	// only touch the environment once the whole snapshot is known to be valid
+	for name, value := range values {
+		i.evaluator.env.DefineVar(name, value)
+	}
+	return nil
+}
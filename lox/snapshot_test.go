@@ -0,0 +1,63 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	source := `var n = 1.5;
+var s = "text";
+var b = true;
+var z = nil;
+var e;
+try { print -"a"; } catch (err) { e = err; }
+fun f() { return n; }
+var g = f;
+`
+	interp := lox.New()
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var buf bytes.Buffer
+	skipped, err := interp.Snapshot(&buf)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if want := []string{"f", "g"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+
+	restored := lox.New()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	for _, name := range []string{"n", "s", "b", "z", "e"} {
+		want, _ := interp.Global(name)
+		got, ok := restored.Global(name)
+		if !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v = %#v, want %#v", name, got, want)
+		}
+	}
+	for _, name := range skipped {
+		if _, ok := restored.Global(name); ok {
+			t.Errorf("skipped function %v was restored", name)
+		}
+	}
+}
+
+func TestSnapshotHostObject(t *testing.T) {
+	interp := lox.New()
+	if err := interp.BindObject("srv", newServer()); err != nil {
+		t.Fatalf("BindObject: %v", err)
+	}
+	_, err := interp.Snapshot(&bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "cannot save host object") {
+		t.Errorf("Snapshot error = %v, want one for the host object", err)
+	}
+}
@@ -0,0 +1,93 @@
+package lox
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// TraceCategory selects which parts of the interpreter write trace records.
+type TraceCategory uint
+
+const (
+	TraceLexer TraceCategory = 1 << iota
+	TraceParser
+	TraceEval
+	TraceEnv
+
+	TraceAll = TraceLexer | TraceParser | TraceEval | TraceEnv
+)
+
+var traceCategoryStr = map[TraceCategory]string{
+	TraceLexer:  "lexer",
+	TraceParser: "parser",
+	TraceEval:   "eval",
+	TraceEnv:    "env",
+}
+
+func (c TraceCategory) String() string {
+	names := make([]string, 0, len(traceCategoryStr))
+	for _, cat := range []TraceCategory{TraceLexer, TraceParser, TraceEval, TraceEnv} {
+		if c&cat != 0 {
+			names = append(names, traceCategoryStr[cat])
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseTraceCategories parses a comma separated list of category names such as
+// "lexer,eval". The name "all" selects every category.
+func ParseTraceCategories(s string) (TraceCategory, error) {
+	var categories TraceCategory
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			categories |= TraceAll
+			continue
+		}
+		found := false
+		for cat, catName := range traceCategoryStr {
+			if name == catName {
+				categories |= cat
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown trace category %q", name)
+		}
+	}
+	return categories, nil
+}
+
+// Tracer writes debug records for the enabled categories to a slog.Logger.
+// A nil Tracer is valid and traces nothing.
+type Tracer struct {
+	categories TraceCategory
+	loggers    map[TraceCategory]*slog.Logger
+}
+
+// NewTracer traces the given categories to logger at slog.LevelDebug, tagging
+// each record with a "category" attribute.
+func NewTracer(logger *slog.Logger, categories TraceCategory) *Tracer {
+	t := &Tracer{categories: categories, loggers: make(map[TraceCategory]*slog.Logger)}
+	for cat, name := range traceCategoryStr {
+		t.loggers[cat] = logger.With("category", name)
+	}
+	return t
+}
+
+// Enabled reports whether records in category c are written. Callers guard
+// calls to Log with it when building the record's arguments costs anything.
+func (t *Tracer) Enabled(c TraceCategory) bool {
+	return t != nil && t.categories&c != 0
+}
+
+func (t *Tracer) Log(c TraceCategory, msg string, args ...any) {
+	if !t.Enabled(c) {
+		return
+	}
+	t.loggers[c].Debug(msg, args...)
+}
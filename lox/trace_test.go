@@ -0,0 +1,97 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestParseTraceCategories(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    lox.TraceCategory
+		str     string
+		wantErr bool
+	}{
+		{"", 0, "", false},
+		{"lexer", lox.TraceLexer, "lexer", false},
+		{"eval, env", lox.TraceEval | lox.TraceEnv, "eval,env", false},
+		{"env,lexer,env", lox.TraceLexer | lox.TraceEnv, "lexer,env", false},
+		{"all", lox.TraceAll, "lexer,parser,eval,env", false},
+		{"parser,all", lox.TraceAll, "lexer,parser,eval,env", false},
+		{"lexer,bogus", 0, "", true},
+	}
+	for _, tt := range tests {
+		got, err := lox.ParseTraceCategories(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTraceCategories(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || got.String() != tt.str {
+			t.Errorf("ParseTraceCategories(%q) = %v (%q), want %v (%q)", tt.in, uint(got), got, uint(tt.want), tt.str)
+		}
+	}
+}
+
+// traceCategories runs source traced for categories and returns the distinct
+// categories of the records written.
+func traceCategories(t *testing.T, source string, categories lox.TraceCategory) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	interp := lox.New(lox.WithTracer(lox.NewTracer(logger, categories)), lox.WithStdout(io.Discard))
+	if err := interp.Run(context.Background(), source); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	seen := make(map[string]bool)
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record struct{ Category string }
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("decode trace record: %v", err)
+		}
+		seen[record.Category] = true
+	}
+	got := make([]string, 0, len(seen))
+	for category := range seen {
+		got = append(got, category)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestTracerCategories(t *testing.T) {
+	const source = "var a = 1;\nprint a + 2;\n"
+	tests := []struct {
+		categories lox.TraceCategory
+		want       []string
+	}{
+		{0, []string{}},
+		{lox.TraceEnv, []string{"env"}},
+		{lox.TraceEval, []string{"eval"}},
+		{lox.TraceEval | lox.TraceEnv, []string{"env", "eval"}},
+		{lox.TraceAll, []string{"env", "eval", "lexer", "parser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.categories.String(), func(t *testing.T) {
+			if got := traceCategories(t, source, tt.categories); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records in categories %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *lox.Tracer
+	if tracer.Enabled(lox.TraceAll) {
+		t.Error("a nil Tracer is enabled")
+	}
+	tracer.Log(lox.TraceEval, "ignored")
+}
@@ -0,0 +1,70 @@
+package lox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+func TestTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"catch binds the thrown value", `try { throw "boom"; } catch (e) { print e; }`, "boom\n"},
+		{"catch binds runtime errors", `try { print -"a"; } catch (e) { print e.message; print e.line; }`, "Operand must be a number\n1\n"},
+		{"catch binding is scoped to the clause", `var e = "outer"; try { throw 1; } catch (e) { print e; } print e;`, "1\nouter\n"},
+		{"catch skipped without an error", `try { print 1; } catch (e) { print "caught"; }`, "1\n"},
+		{"statements after the throw do not run", `try { throw 1; print "after"; } catch (e) { print "caught"; }`, "caught\n"},
+		{"finally runs after the body", `try { print 1; } finally { print 2; } print 3;`, "1\n2\n3\n"},
+		{"finally runs after the catch", `try { throw 1; } catch (e) { print "catch"; } finally { print "finally"; }`, "catch\nfinally\n"},
+		{
+			"finally runs when returning",
+			`fun f() { try { return "body"; } finally { print "finally"; } } print f();`,
+			"finally\nbody\n",
+		},
+		{
+			"finally runs before an error propagates",
+			`try { try { throw 1; } finally { print "inner"; } } catch (e) { print "outer " + "caught"; }`,
+			"inner\nouter caught\n",
+		},
+		{
+			"an error in finally replaces the pending one",
+			`try { try { throw 1; } finally { throw 2; } } catch (e) { print e; }`,
+			"2\n",
+		},
+		{"rethrow from the catch", `try { try { throw 1; } catch (e) { throw e + 1; } } catch (e) { print e; }`, "2\n"},
+		{
+			"rethrow through a function",
+			`fun f() { try { throw "x"; } catch (e) { throw e; } } try { f(); } catch (e) { print "again " + e; }`,
+			"again x\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := lox.New(lox.WithStdout(&out)).Run(context.Background(), tt.source); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	var out bytes.Buffer
+	err := lox.New(lox.WithStdout(&out)).Run(context.Background(), "print 1;\ntry { throw \"x\"; } finally { print 2; }\nprint 3;")
+	var throwErr lox.ThrowError
+	if !errors.As(err, &throwErr) || err.Error() != "uncaught exception: x" {
+		t.Errorf("Run error = %v, want an uncaught ThrowError of x", err)
+	}
+	if out.String() != "1\n2\n" {
+		t.Errorf("output = %q, want %q", out.String(), "1\n2\n")
+	}
+}
@@ -0,0 +1,132 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DecodeError describes a global that could not be decoded by Unmarshal. Line
+// and Column locate the name in the global's declaration, or are 0 if it was
+// never declared.
+type DecodeError struct {
+	Line    int
+	Column  int
+	Name    string
+	Message string
+}
+
+func (e DecodeError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%v: %v", e.Name, e.Message)
+	}
+	return fmt.Sprintf("[line %d] %v: %v", e.Line, e.Name, e.Message)
+}
+
+// Unmarshal runs source as a configuration script and stores its globals in
+// the struct v points to.
+//
+// Each exported field is filled from the global named by its `lox:"name"` tag,
+// or else from the global matching the field name, ignoring case. A tag of
+// "-" skips the field and the option "optional", as in `lox:"port,optional"`,
+// allows the global to be absent. Unmarshal reports every missing global,
+// every variable declared at the top level without a matching field and every
+// value of the wrong type as DecodeErrors joined into a single error.
+func Unmarshal(ctx context.Context, source string, v any, opts ...Option) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal: expected a non-nil pointer to a struct, got %T", v)
+	}
+	i := New(opts...)
+	block, err := i.Parse(source)
+	if err != nil {
+		return err
+	}
+	if err := i.Exec(ctx, &Program{block: block}); err != nil {
+		return err
+	}
+
+	// declared maps the script's top-level variables to their declared names
+	declared := make(map[string]Token)
+	for _, s := range block.Body {
+		if decl, ok := s.(VarDeclStmt); ok {
+			declared[decl.Name.Literal] = decl.Name
+		}
+	}
+
+	var errs []error
+	target := ptr.Elem()
+	used := make(map[string]bool)
+	for _, field := range reflect.VisibleFields(target.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, optional, skip := parseLoxTag(field)
+		if skip {
+			continue
+		}
+		global, ok := lookupGlobal(i, name, field.Tag.Get("lox") == "")
+		if !ok {
+			if !optional {
+				errs = append(errs, DecodeError{Name: name, Message: "missing global"})
+			}
+			continue
+		}
+		used[global] = true
+		value, _ := i.Global(global)
+		converted, err := toGo(value, field.Type)
+		if err != nil {
+			errs = append(errs, decodeError(declared[global], global, "cannot decode into field %v: %v", field.Name, err))
+			continue
+		}
+		// promoted fields of a nil embedded pointer have nowhere to go
+		dst, err := target.FieldByIndexErr(field.Index)
+		if err != nil {
+			errs = append(errs, decodeError(declared[global], global, "cannot decode into field %v: %v", field.Name, err))
+			continue
+		}
+		dst.Set(converted)
+	}
+	for _, s := range block.Body {
+		if decl, ok := s.(VarDeclStmt); ok && !used[decl.Name.Literal] {
+			used[decl.Name.Literal] = true
+			errs = append(errs, decodeError(decl.Name, decl.Name.Literal, "no matching field"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeError reports the global name, declared at tok, as undecodable.
+func decodeError(tok Token, name string, format string, args ...any) DecodeError {
+	return DecodeError{Line: tok.Line, Column: tok.Column, Name: name, Message: fmt.Sprintf(format, args...)}
+}
+
+func parseLoxTag(field reflect.StructField) (name string, optional bool, skip bool) {
+	tag := field.Tag.Get("lox")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, opts == "optional", false
+}
+
+// lookupGlobal finds the global called name, falling back to a case-insensitive
+// match if fold is set.
+func lookupGlobal(i *Interpreter, name string, fold bool) (string, bool) {
+	if _, ok := i.Global(name); ok {
+		return name, true
+	}
+	if fold {
+		for _, global := range i.Globals() {
+			if strings.EqualFold(global, name) {
+				return global, true
+			}
+		}
+	}
+	return "", false
+}
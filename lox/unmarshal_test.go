@@ -0,0 +1,56 @@
+package lox_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/lox"
+)
+
+type Inner struct {
+	Port float64
+}
+
+func TestUnmarshalNilEmbeddedPointer(t *testing.T) {
+	var cfg struct {
+		*Inner
+		Host string
+	}
+	err := lox.Unmarshal(context.Background(), "var host = \"localhost\";\nvar port = 8080;\n", &cfg)
+	var decodeErr lox.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("Unmarshal error = %v, want a DecodeError", err)
+	}
+	if decodeErr.Name != "port" || decodeErr.Line != 2 || decodeErr.Column != 5 {
+		t.Errorf("DecodeError = %+v, want one for port at 2:5", decodeErr)
+	}
+	if cfg.Host != "localhost" {
+		t.Errorf("Host = %q, want %q", cfg.Host, "localhost")
+	}
+}
+
+func TestUnmarshalErrorPositions(t *testing.T) {
+	var cfg struct {
+		Host string
+		Port float64
+	}
+	source := "var host = 1;\n  var   port = 80; var extra = 1;\n"
+	err := lox.Unmarshal(context.Background(), source, &cfg)
+	var got []lox.DecodeError
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var decodeErr lox.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("error %v is not a DecodeError", err)
+		}
+		got = append(got, decodeErr)
+	}
+	want := []lox.DecodeError{
+		{Line: 1, Column: 5, Name: "host", Message: "cannot decode into field Host: expected string, got number"},
+		{Line: 2, Column: 24, Name: "extra", Message: "no matching field"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %#v\nwant %#v", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package lox
+
+import "reflect"
+
+// Inspect calls fn for node, a Statement or Expression, and if fn returns true
+// goes on to inspect each of node's children in turn.
+func Inspect(node any, fn func(node any) bool) {
+	inspect(reflect.ValueOf(node), fn)
+}
+
+func inspect(v reflect.Value, fn func(node any) bool) {
+	if !fn(v.Interface()) {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Type() == posType, field.Type() == tokenType:
+		case field.Kind() == reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.Interface {
+				continue // tokens
+			}
+			for j := 0; j < field.Len(); j++ {
+				inspect(field.Index(j).Elem(), fn)
+			}
+		case field.Kind() == reflect.Interface || field.Kind() == reflect.Pointer:
+			if !field.IsNil() {
+				inspect(field.Elem(), fn)
+			}
+		case field.Kind() == reflect.Struct:
+			inspect(field, fn)
+		}
+	}
+}